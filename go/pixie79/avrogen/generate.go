@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+
+	pUtils "pixie79/utils"
+)
+
+// generator emits the Go source for one Avro schema.
+type generator struct {
+	buf      bytes.Buffer
+	imports  map[string]bool
+	goNames  map[string]string
+	declared map[*pUtils.AvroSchema]bool
+	pending  []*pUtils.AvroSchema
+}
+
+// generate returns the formatted Go source for the records and enums of schema, registering the
+// root record as eventType.
+func generate(schema, schemaFile, eventType string) ([]byte, error) {
+	root, err := pUtils.ParseAvroSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+	if root.Type != "record" {
+		return nil, fmt.Errorf("the root of the schema must be a record, not %s", root.Type)
+	}
+	if eventType == "" {
+		eventType = lowerCamel(goName(unqualified(root.Name)))
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, []byte(schema)); err != nil {
+		return nil, err
+	}
+
+	g := &generator{
+		imports:  map[string]bool{"encoding/json": true, "reflect": true},
+		goNames:  map[string]string{},
+		declared: map[*pUtils.AvroSchema]bool{},
+	}
+	if err := g.collectNames(root, map[*pUtils.AvroSchema]bool{}); err != nil {
+		return nil, err
+	}
+
+	g.pending = []*pUtils.AvroSchema{root}
+	for len(g.pending) > 0 {
+		next := g.pending[0]
+		g.pending = g.pending[1:]
+		if g.declared[next] {
+			continue
+		}
+		g.declared[next] = true
+		if next.Type == "enum" {
+			g.writeEnum(next)
+		} else {
+			g.writeRecord(next)
+		}
+	}
+
+	schemaConst := lowerCamel(g.goNames[root.Name]) + "AvroSchema"
+	fmt.Fprintf(&g.buf, "const %s = %s\n\n", schemaConst, strconv.Quote(compact.String()))
+	fmt.Fprintf(&g.buf, `func init() {
+	RegisterEventType(EventType{
+		Name:     %q,
+		AvroName: %q,
+		Schema:   %s,
+		Type:     reflect.TypeOf(%s{}),
+		Convert: func(jsonData []byte) (map[string]interface{}, error) {
+			var event %s
+			if err := json.Unmarshal(jsonData, &event); err != nil {
+				return nil, err
+			}
+			return event.ToAvro(), nil
+		},
+	})
+}
+`, eventType, root.Name, schemaConst, g.goNames[root.Name], g.goNames[root.Name])
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by avrogen from %s. DO NOT EDIT.\n\npackage types\n\nimport (\n", schemaFile)
+	imports := make([]string, 0, len(g.imports))
+	for imp := range g.imports {
+		imports = append(imports, imp)
+	}
+	sort.Strings(imports)
+	for _, imp := range imports {
+		fmt.Fprintf(&out, "\t%q\n", imp)
+	}
+	out.WriteString(")\n\n")
+	out.Write(g.buf.Bytes())
+
+	formatted, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated source does not compile: %w", err)
+	}
+	return formatted, nil
+}
+
+// collectNames assigns a Go type name to every record and enum, rejecting names that clash once
+// namespaces are dropped.
+func (g *generator) collectNames(schema *pUtils.AvroSchema, seen map[*pUtils.AvroSchema]bool) error {
+	if seen[schema] {
+		return nil
+	}
+	seen[schema] = true
+
+	switch schema.Type {
+	case "record", "error", "enum":
+		name := goName(unqualified(schema.Name))
+		for fullName, other := range g.goNames {
+			if other == name && fullName != schema.Name {
+				return fmt.Errorf("%s and %s both generate the Go type %s", fullName, schema.Name, name)
+			}
+		}
+		g.goNames[schema.Name] = name
+		for _, field := range schema.Fields {
+			if err := g.collectNames(field.Type, seen); err != nil {
+				return err
+			}
+		}
+	case "array":
+		return g.collectNames(schema.Items, seen)
+	case "map":
+		return g.collectNames(schema.Values, seen)
+	case "union":
+		for _, branch := range schema.Branches {
+			if err := g.collectNames(branch, seen); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (g *generator) writeEnum(schema *pUtils.AvroSchema) {
+	name := g.goNames[schema.Name]
+	fmt.Fprintf(&g.buf, "// %s is generated from the Avro enum %s.\ntype %s string\n\nconst (\n", name, schema.Name, name)
+	for _, symbol := range schema.Symbols {
+		fmt.Fprintf(&g.buf, "\t%s%s %s = %q\n", name, goName(symbol), name, symbol)
+	}
+	g.buf.WriteString(")\n\n")
+}
+
+func (g *generator) writeRecord(schema *pUtils.AvroSchema) {
+	name := g.goNames[schema.Name]
+	fieldNames := make([]string, len(schema.Fields))
+	used := map[string]bool{}
+	for i, field := range schema.Fields {
+		fieldName := goName(field.Name)
+		for used[fieldName] {
+			fieldName += "_"
+		}
+		used[fieldName] = true
+		fieldNames[i] = fieldName
+	}
+
+	fmt.Fprintf(&g.buf, "// %s is generated from the Avro record %s.\ntype %s struct {\n", name, schema.Name, name)
+	for i, field := range schema.Fields {
+		tag := field.Name
+		if nullableBranch(field.Type) != nil {
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(&g.buf, "\t%s %s `json:%q avro:%q`\n", fieldNames[i], g.goType(field.Type), tag, field.Name)
+	}
+	g.buf.WriteString("}\n\n")
+
+	fmt.Fprintf(&g.buf, "// ToAvro converts the record to goavro native data.\nfunc (r %s) ToAvro() map[string]interface{} {\n\treturn map[string]interface{}{\n", name)
+	for i, field := range schema.Fields {
+		fmt.Fprintf(&g.buf, "\t\t%q: %s,\n", field.Name, g.toNative("r."+fieldNames[i], field.Type, 0))
+	}
+	g.buf.WriteString("\t}\n}\n\n")
+
+	fmt.Fprintf(&g.buf, "// FromAvro fills the record from goavro native data.\nfunc (r *%s) FromAvro(native map[string]interface{}) error {\n\treturn r.fromAvro(native, \"\")\n}\n\n", name)
+	fmt.Fprintf(&g.buf, "func (r *%s) fromAvro(native map[string]interface{}, path string) error {\n", name)
+	for i, field := range schema.Fields {
+		g.fromNative("r."+fieldNames[i], fmt.Sprintf("native[%q]", field.Name), fmt.Sprintf("joinAvroPath(path, %q)", field.Name), field.Type, 0)
+	}
+	g.buf.WriteString("\treturn nil\n}\n\n")
+}
+
+// goType returns the Go type used for values of schema.
+func (g *generator) goType(schema *pUtils.AvroSchema) string {
+	switch schema.Type {
+	case "null":
+		return "interface{}"
+	case "boolean":
+		return "bool"
+	case "int":
+		switch schema.Logical {
+		case "date":
+			return "CustpTime"
+		case "time-millis":
+			g.imports["time"] = true
+			return "time.Duration"
+		}
+		return "int32"
+	case "long":
+		switch schema.Logical {
+		case "timestamp-millis", "timestamp-micros":
+			return "CustpTime"
+		case "time-micros":
+			g.imports["time"] = true
+			return "time.Duration"
+		}
+		return "int64"
+	case "float":
+		return "float32"
+	case "double":
+		return "float64"
+	case "string":
+		return "string"
+	case "bytes", "fixed":
+		if schema.Logical == "decimal" && schema.Type == "bytes" {
+			g.imports["math/big"] = true
+			return "*big.Rat"
+		}
+		return "[]byte"
+	case "record", "error", "enum":
+		g.pending = append(g.pending, schema)
+		return g.goNames[schema.Name]
+	case "array":
+		return "[]" + g.goType(schema.Items)
+	case "map":
+		return "map[string]" + g.goType(schema.Values)
+	case "union":
+		if branch := nullableBranch(schema); branch != nil {
+			return "*" + g.goType(branch)
+		}
+		return "interface{}"
+	}
+	return "interface{}"
+}
+
+// toNative returns an expression converting the Go value expr into goavro native data.
+func (g *generator) toNative(expr string, schema *pUtils.AvroSchema, depth int) string {
+	switch schema.Type {
+	case "int", "long":
+		if g.goType(schema) == "CustpTime" {
+			return expr + ".Time"
+		}
+		return expr
+	case "record", "error":
+		return expr + ".ToAvro()"
+	case "enum":
+		return "string(" + expr + ")"
+	case "array":
+		item := fmt.Sprintf("item%d", depth)
+		return fmt.Sprintf("func() []interface{} {\nitems := make([]interface{}, 0, len(%s))\nfor _, %s := range %s {\nitems = append(items, %s)\n}\nreturn items\n}()",
+			expr, item, expr, g.toNative(item, schema.Items, depth+1))
+	case "map":
+		value := fmt.Sprintf("value%d", depth)
+		return fmt.Sprintf("func() map[string]interface{} {\nvalues := make(map[string]interface{}, len(%s))\nfor key, %s := range %s {\nvalues[key] = %s\n}\nreturn values\n}()",
+			expr, value, expr, g.toNative(value, schema.Values, depth+1))
+	case "union":
+		branch := nullableBranch(schema)
+		if branch == nil {
+			return expr
+		}
+		return fmt.Sprintf("func() interface{} {\nif %s == nil {\nreturn nil\n}\nreturn map[string]interface{}{%q: %s}\n}()",
+			expr, branch.BranchName(), g.toNative("(*"+expr+")", branch, depth+1))
+	}
+	return expr
+}
+
+// fromNative writes statements assigning the goavro native value src, found at the runtime path
+// expression path, to target.
+func (g *generator) fromNative(target, src, path string, schema *pUtils.AvroSchema, depth int) {
+	g.buf.WriteString("{\n")
+	defer g.buf.WriteString("}\n")
+
+	switch schema.Type {
+	case "record", "error":
+		fmt.Fprintf(&g.buf, "record, err := avroValue[map[string]interface{}](%s, %s)\nif err != nil {\nreturn err\n}\nif err := %s.fromAvro(record, %s); err != nil {\nreturn err\n}\n",
+			src, path, target, path)
+	case "enum":
+		fmt.Fprintf(&g.buf, "symbol, err := avroValue[string](%s, %s)\nif err != nil {\nreturn err\n}\n%s = %s(symbol)\n",
+			src, path, target, g.goNames[schema.Name])
+	case "array":
+		index, item := fmt.Sprintf("i%d", depth), fmt.Sprintf("item%d", depth)
+		fmt.Fprintf(&g.buf, "items, err := avroValue[[]interface{}](%s, %s)\nif err != nil {\nreturn err\n}\n%s = make(%s, len(items))\nfor %s, %s := range items {\n",
+			src, path, target, g.goType(schema), index, item)
+		g.fromNative(target+"["+index+"]", item, fmt.Sprintf("indexAvroPath(%s, %s)", path, index), schema.Items, depth+1)
+		g.buf.WriteString("}\n")
+	case "map":
+		key, value, elem := fmt.Sprintf("key%d", depth), fmt.Sprintf("value%d", depth), fmt.Sprintf("elem%d", depth)
+		fmt.Fprintf(&g.buf, "values, err := avroValue[map[string]interface{}](%s, %s)\nif err != nil {\nreturn err\n}\n%s = make(%s, len(values))\nfor %s, %s := range values {\nvar %s %s\n",
+			src, path, target, g.goType(schema), key, value, elem, g.goType(schema.Values))
+		g.fromNative(elem, value, fmt.Sprintf("joinAvroPath(%s, %s)", path, key), schema.Values, depth+1)
+		fmt.Fprintf(&g.buf, "%s[%s] = %s\n}\n", target, key, elem)
+	case "union":
+		branch := nullableBranch(schema)
+		if branch == nil {
+			fmt.Fprintf(&g.buf, "%s = %s\n", target, src)
+			return
+		}
+		value, elem := fmt.Sprintf("value%d", depth), fmt.Sprintf("elem%d", depth)
+		fmt.Fprintf(&g.buf, "%s, err := avroBranch(%s, %q, %s)\nif err != nil {\nreturn err\n}\n%s = nil\nif %s != nil {\nvar %s %s\n",
+			value, src, branch.BranchName(), path, target, value, elem, g.goType(branch))
+		g.fromNative(elem, value, path, branch, depth+1)
+		fmt.Fprintf(&g.buf, "%s = &%s\n}\n", target, elem)
+	case "null":
+		fmt.Fprintf(&g.buf, "%s = %s\n", target, src)
+	default:
+		goType := g.goType(schema)
+		nativeType := goType
+		if goType == "CustpTime" {
+			g.imports["time"] = true
+			nativeType = "time.Time"
+		}
+		fmt.Fprintf(&g.buf, "value, err := avroValue[%s](%s, %s)\nif err != nil {\nreturn err\n}\n", nativeType, src, path)
+		if goType == "CustpTime" {
+			fmt.Fprintf(&g.buf, "%s = CustpTime{Time: value}\n", target)
+		} else {
+			fmt.Fprintf(&g.buf, "%s = value\n", target)
+		}
+	}
+}
+
+// nullableBranch returns the non-null branch of a two branch union with a null branch.
+func nullableBranch(schema *pUtils.AvroSchema) *pUtils.AvroSchema {
+	if schema.Type != "union" || len(schema.Branches) != 2 {
+		return nil
+	}
+	switch {
+	case schema.Branches[0].Type == "null" && schema.Branches[1].Type != "null":
+		return schema.Branches[1]
+	case schema.Branches[1].Type == "null" && schema.Branches[0].Type != "null":
+		return schema.Branches[0]
+	}
+	return nil
+}
+
+// goName converts an Avro name such as message_key or IN_PROGRESS to an exported Go name.
+func goName(name string) string {
+	parts := strings.FieldsFunc(name, func(r rune) bool {
+		return r == '_' || r == '-' || r == '.'
+	})
+	var b strings.Builder
+	for _, part := range parts {
+		if strings.ToUpper(part) == part {
+			part = strings.ToLower(part)
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	if b.Len() == 0 || (b.String()[0] >= '0' && b.String()[0] <= '9') {
+		return "X" + b.String()
+	}
+	return b.String()
+}
+
+func lowerCamel(name string) string {
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+func unqualified(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
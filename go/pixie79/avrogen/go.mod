@@ -0,0 +1,3 @@
+module pixie79/avrogen
+
+go 1.22.4
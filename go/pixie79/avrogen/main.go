@@ -0,0 +1,50 @@
+// Command avrogen generates Go types for the records of an Avro schema, with converters to and from
+// goavro native data, and registers the root record as an event type. The generated file belongs to
+// the pixie79/types package, whose helpers it uses.
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	pUtils "pixie79/utils"
+)
+
+func main() {
+	pUtils.SetupLogger()
+
+	var (
+		schemaFile = flag.String("schema", "", "Avro schema (.avsc) to generate Go types from")
+		outputFile = flag.String("o", "", "Output file, defaults to the schema file name with an _avro.go suffix")
+		eventType  = flag.String("t", "", "Event type name to register, defaults to the root record name in lower camel case")
+	)
+	flag.Parse()
+
+	if *schemaFile == "" {
+		slog.Error("A schema file is required, set -schema")
+		os.Exit(1)
+	}
+	if *outputFile == "" {
+		base := filepath.Base(*schemaFile)
+		*outputFile = base[:len(base)-len(filepath.Ext(base))] + "_avro.go"
+	}
+
+	schema, err := os.ReadFile(*schemaFile)
+	if err != nil {
+		slog.Error("Error reading schema", "Error", err)
+		os.Exit(1)
+	}
+
+	source, err := generate(string(schema), filepath.Base(*schemaFile), *eventType)
+	if err != nil {
+		slog.Error("Error generating Go types", "schema", *schemaFile, "Error", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(*outputFile, source, 0o644); err != nil {
+		slog.Error("Error writing generated file", "Error", err)
+		os.Exit(1)
+	}
+	slog.Info("Generated Go types", "schema", *schemaFile, "output", *outputFile)
+}
@@ -4,20 +4,27 @@ import (
 	"math/rand"
 	pTypes "pixie79/types"
 	pUtils "pixie79/utils"
+	"time"
 )
 
-func generateTestEventDemoEvent(customers []pTypes.TestCustomer) pTypes.DemoEvent {
+func init() {
+	pTypes.RegisterEventGenerator("customerEvent", func(customers []pTypes.TestCustomer) interface{} {
+		return generateTestEventCustomerEvent(customers)
+	})
+}
+
+func generateTestEventCustomerEvent(customers []pTypes.TestCustomer) pTypes.CustomerEvent {
 	customer := customers[rand.Intn(len(customers))]
 
 	// Generate the business data payload with random values
-	Payload := pTypes.DemoEventPayload{
+	Payload := pTypes.Customer{
 		Id:                 pUtils.GenerateRandomString("PK", 6),
-		NamePrefix:         pUtils.IfEmptyReturnNilString(pUtils.RandomChoiceString([]string{"Mr", "Mrs", "Ms", ""})),
+		Title:              pUtils.IfEmptyReturnNilString(pUtils.RandomChoiceString([]string{"Mr", "Mrs", "Ms", ""})),
 		PreferredName:      pUtils.IfEmptyReturnNilString(&customer.GivenName),
 		GivenName:          pUtils.IfEmptyReturnNilString(&customer.GivenName),
 		LastName:           pUtils.IfEmptyReturnNilString(&customer.LastName),
 		MiddleName:         pUtils.IfEmptyReturnNilString(pUtils.RandomChoiceString([]string{"A", "B", "C", ""})),
-		DateOfBirth:        pUtils.DateOrNil(),
+		DateOfBirth:        dateOrNil(),
 		DateOfDeath:        nil, // Not likely to be populated in a normal case
 		Gender:             pUtils.IfEmptyReturnNilString(pUtils.RandomChoiceString([]string{"Male", "Female", ""})),
 		PlaceOfBirth:       pUtils.IfEmptyReturnNilString(pUtils.RandomChoiceString([]string{"London", "New York", "Sydney", ""})),
@@ -25,9 +32,17 @@ func generateTestEventDemoEvent(customers []pTypes.TestCustomer) pTypes.DemoEven
 	}
 
 	// Return the complete test event
-	return pTypes.DemoEvent{
+	return pTypes.CustomerEvent{
 		Metadata: generateMetadata(), // Generate the metadata
 		Payload:  Payload,            // Assign the business data payload
 	}
 
 }
+
+// dateOrNil returns a random date, or nil for about one in five customers.
+func dateOrNil() *pTypes.CustpTime {
+	if rand.Float64() < 0.2 {
+		return nil
+	}
+	return &pTypes.CustpTime{Time: pUtils.GenerateRandomDate().UTC().Truncate(24 * time.Hour)}
+}
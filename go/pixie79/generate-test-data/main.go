@@ -3,11 +3,14 @@ package main
 import (
 	"encoding/json"
 	"flag"
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	pTypes "pixie79/types"
 	pUtils "pixie79/utils"
 	"strconv"
+	"strings"
 )
 
 const (
@@ -54,6 +57,9 @@ const (
 			"national_identity_numbers": [""]
 		}
 	]`
+
+	formatJSON = "json"
+	formatOCF  = "ocf"
 )
 
 func main() {
@@ -61,7 +67,7 @@ func main() {
 	// Default values for flags
 	defaultNumEvents := 2000
 	defaultFilename := "demo_event_data.json"
-	defaultEventType := "demoEvent" // Default event type to generate
+	defaultEventType := "customerEvent" // Default event type to generate
 
 	var customers []pTypes.TestCustomer
 	if err := json.Unmarshal([]byte(defaultCustomers), &customers); err != nil {
@@ -72,24 +78,29 @@ func main() {
 	// Flags for custom input
 	numEvents := flag.Int("n", defaultNumEvents, "Number of events to generate")
 	outputFilename := flag.String("o", defaultFilename, "Output filename")
-	eventType := flag.String("t", defaultEventType, "Type of event data to generate (e.g., 'demoEvent', 'alternative')")
+	eventType := flag.String("t", defaultEventType, "Type of event data to generate: "+strings.Join(generatedEventTypes(), ", "))
+	format := flag.String("f", formatJSON, "Output format, 'json' or 'ocf' for an Avro object container file")
+	schemaFile := flag.String("schema", "../schemas/demo.avsc", "Avro schema written to the header of an OCF file")
+	compression := flag.String("compression", "deflate", "OCF block compression: null, deflate or snappy")
 	flag.Parse()
 
-	var events []interface{}
+	if *format != formatJSON && *format != formatOCF {
+		slog.Error("Unknown output format", "Error", *format)
+		return
+	}
 
 	// Determine the type of data to generate based on the CLI argument
-	switch *eventType {
-	case "demoEvent":
-		events = make([]interface{}, *numEvents)
-		for i := 0; i < *numEvents; i++ {
-			events[i] = generateTestEventDemoEvent(customers)
-		}
-
-	default:
-		slog.Error("Unknown event type", "Error", *eventType)
+	registered, ok := pTypes.LookupEventType(*eventType)
+	if !ok || registered.Generate == nil {
+		slog.Error("Unknown event type", "Error", *eventType, "supported", generatedEventTypes())
 		return
 	}
 
+	events := make([]interface{}, *numEvents)
+	for i := 0; i < *numEvents; i++ {
+		events[i] = registered.Generate(customers)
+	}
+
 	// Serialize to JSON and save to file
 	file, err := os.Create(*outputFilename)
 	if err != nil {
@@ -98,12 +109,52 @@ func main() {
 	}
 	defer file.Close()
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ") // Pretty print
-	if err := encoder.Encode(events); err != nil {
-		slog.Error("Error encoding JSON", "Error", err)
-		return
+	if *format == formatOCF {
+		if err := writeOCF(file, *schemaFile, *compression, events); err != nil {
+			slog.Error("Error writing OCF", "Error", err)
+			return
+		}
+	} else {
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ") // Pretty print
+		if err := encoder.Encode(events); err != nil {
+			slog.Error("Error encoding JSON", "Error", err)
+			return
+		}
 	}
 
-	slog.Info("Generating", "events", strconv.Itoa(*numEvents), "Type", *eventType, "Output File", *outputFilename)
+	slog.Info("Generating", "events", strconv.Itoa(*numEvents), "Type", *eventType, "Format", *format, "Output File", *outputFilename)
+}
+
+// writeOCF writes the events as an Avro object container file with the schema read from schemaFile.
+// Events are converted from their avro struct tags, so any tagged event type can be written.
+func writeOCF(w io.Writer, schemaFile string, compression string, events []interface{}) error {
+	source, err := os.ReadFile(schemaFile)
+	if err != nil {
+		return err
+	}
+	schema, err := pUtils.ParseAvroSchema(string(source))
+	if err != nil {
+		return err
+	}
+
+	records := make([]interface{}, len(events))
+	for i, event := range events {
+		records[i], err = pUtils.AvroNativeFromStruct(schema, event)
+		if err != nil {
+			return fmt.Errorf("event %d: %w", i, err)
+		}
+	}
+	return pUtils.WriteOCF(w, string(source), records, compression)
+}
+
+// generatedEventTypes returns the registered event types that have a test data generator.
+func generatedEventTypes() []string {
+	var names []string
+	for _, name := range pTypes.EventTypeNames() {
+		if eventType, _ := pTypes.LookupEventType(name); eventType.Generate != nil {
+			names = append(names, name)
+		}
+	}
+	return names
 }
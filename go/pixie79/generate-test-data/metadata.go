@@ -5,8 +5,8 @@ import (
 	pUtils "pixie79/utils"
 )
 
-func generateMetadata() pTypes.Metadata {
-	return pTypes.Metadata{
+func generateMetadata() pTypes.EventMetadata {
+	return pTypes.EventMetadata{
 		MessageKey:          pUtils.GenerateRandomString("", 10),
 		CreatedDate:         pTypes.CustpTime{Time: pUtils.GenerateRandomDate()},
 		UpdatedDate:         pTypes.CustpTime{Time: pUtils.GenerateRandomDate()},
@@ -6,7 +6,12 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	pTypes "pixie79/types"
 	pUtils "pixie79/utils"
 	pKgo "pixie79/utils/kgo"
 
@@ -15,14 +20,40 @@ import (
 	"github.com/twmb/franz-go/pkg/kgo"
 )
 
+// defaultAvroKeyField is the key field of Avro key schemas that are not records, when -key-field is empty.
+const defaultAvroKeyField = "metadata.message_key"
+
 var (
-	destinationTopic    string
-	destinationSchemaID string
-	schemaURL           string
-	seedEnv             string
-	seeds               []string
+	destinationTopic string
+	schemaURL        string
+	seeds            []string
+	producerOptions  pKgo.ProducerOptions
+	recordFields     pKgo.RecordFields
+	extraHeaders     headerFlags
+	sourceFile       string
+	loadRunID        string
 )
 
+// headerFlags collects the -H key=value flags as record headers.
+type headerFlags []kgo.RecordHeader
+
+func (h *headerFlags) String() string {
+	pairs := make([]string, len(*h))
+	for i, header := range *h {
+		pairs[i] = header.Key + "=" + string(header.Value)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (h *headerFlags) Set(value string) error {
+	key, headerValue, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("header %q is not key=value", value)
+	}
+	*h = append(*h, kgo.RecordHeader{Key: key, Value: []byte(headerValue)})
+	return nil
+}
+
 func init() {
 
 	err := godotenv.Load()
@@ -37,21 +68,9 @@ func init() {
 		panic("REDPANDA_INPUT_TOPIC environment variable is required")
 	}
 
-	destinationSchemaID = os.Getenv("DESTINATION_SCHEMA_ID")
-	if destinationSchemaID == "" {
-		panic("DESTINATION_SCHEMA_ID environment variable is required")
+	if _, err := pUtils.DestinationSchemaFromEnv(); err != nil {
+		panic(err.Error())
 	}
-
-	schemaURL = os.Getenv("SCHEMA_REGISTRY_URL")
-	if schemaURL == "" {
-		panic("SCHEMA_REGISTRY_URL environment variable is required")
-	}
-
-	seedEnv = os.Getenv("REDPANDA_SEED_URL")
-	if seedEnv == "" {
-		panic("REDPANDA_SEED_URL environment variable is required")
-	}
-	seeds = []string{seedEnv}
 }
 
 func setupLoader() (*avro.Codec, []byte, string) {
@@ -70,41 +89,201 @@ func setupLoader() (*avro.Codec, []byte, string) {
 func main() {
 	var (
 		avroRecords      []*kgo.Record
-		defaultEventType = "onboardingDataIdentityVerificationSelfieV2"
-		fileName         = flag.String("filename", "", "filename")
+		defaultEventType = "customerEvent"
+		fileName         = flag.String("filename", "", "JSON array or Avro object container file to load")
 	)
 
-	eventType := flag.String("t", defaultEventType, "Type of event data to generate (e.g., 'onboardingDataIdentityVerificationSelfieV2', 'alternative')")
+	eventType := flag.String("t", defaultEventType, "Event type of the JSON data: "+strings.Join(pTypes.EventTypeNames(), ", ")+", or empty to convert using only the destination schema")
+	messageName := flag.String("message", "", "Fully qualified Protobuf message to produce, defaults to the first message in the schema")
+	flag.StringVar(&recordFields.Key, "key-field", "", "Field used as the key, unless the Avro key schema is a record; empty keeps a fixed key, or uses "+defaultAvroKeyField+" for other Avro key schemas")
+	flag.StringVar(&recordFields.Timestamp, "timestamp-field", "", "Field used as the record timestamp, such as metadata.updated_date; empty uses the time it is produced")
+	flag.StringVar(&recordFields.Partition, "partition-field", "", "Field holding the partition number, required by the manual partitioner")
+	flag.StringVar(&producerOptions.Partitioner, "partitioner", pKgo.PartitionerMurmur2, "Partitioner: "+strings.Join(pKgo.Partitioners, ", "))
+	flag.IntVar(&producerOptions.TransactionSize, "transaction-size", 0, "Records committed per transaction, 0 loads the whole file in one transaction")
+	idempotent := flag.Bool("idempotent", false, "Produce with the idempotent producer instead of transactions")
+	flag.IntVar(&producerOptions.Retry.Attempts, "retries", 3, "Times a transaction is produced before its records fail, when its errors are retriable; ignored with -idempotent")
+	flag.DurationVar(&producerOptions.Retry.Backoff, "retry-backoff", time.Second, "Wait before the first retry, doubled before each further retry")
+	flag.StringVar(&producerOptions.FailedRecordsFile, "failed-records", "", "File that records which could not be produced are appended to")
+	flag.Var(&extraHeaders, "H", "Header added to every record as key=value, may be repeated")
+	replayFile := flag.String("replay-failed", "", "Produce the records of a -failed-records file instead of loading -filename")
+	connectionFlags := pKgo.RegisterConnectionFlags(flag.CommandLine)
 	flag.Parse()
 
-	destinationCodec, hdr, destinationTopic := setupLoader()
+	connection, err := connectionFlags.Config()
+	if err != nil {
+		panic(fmt.Sprintf("Invalid connection settings: %v", err))
+	}
+	pKgo.SetConnectionConfig(connection)
+	producerOptions.Transactional = !*idempotent
+
+	seeds = connection.Kafka.Brokers
+	if len(seeds) == 0 {
+		panic("Kafka brokers are required, set REDPANDA_SEED_URL, -brokers or use an rpk profile")
+	}
+	schemaURL = connection.SchemaRegistryURL()
+	if schemaURL == "" {
+		panic("A schema registry is required, set SCHEMA_REGISTRY_URL, -schema-registry or use an rpk profile")
+	}
+
+	if producerOptions.Partitioner == pKgo.PartitionerManual && recordFields.Partition == "" {
+		panic("The manual partitioner needs -partition-field")
+	}
+
+	if *replayFile != "" {
+		records, err := pKgo.ReadFailedRecords(*replayFile)
+		if err != nil {
+			slog.Error("Error reading failed records", "Error", err)
+			return
+		}
+		submitRecords(records)
+		return
+	}
 
-	// Load JSON data from file
+	if _, ok := pTypes.LookupEventType(*eventType); *eventType != "" && !ok {
+		slog.Error("Unknown event type", "Error", *eventType, "supported", pTypes.EventTypeNames())
+		return
+	}
+
+	// Load JSON data, or an Avro object container file, from file
 	jsonData, err := os.ReadFile(*fileName)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to read JSON file: %v", err))
 	}
+	sourceFile = filepath.Base(*fileName)
+	loadRunID = time.Now().UTC().Format("20060102T150405Z") + "-" + pUtils.RandomString(8)
+	slog.Info("Loading records", "file", sourceFile, "run", loadRunID)
+
+	schemaType, err := pKgo.FetchDestinationSchemaType(schemaURL)
+	if err != nil {
+		panic(fmt.Sprintf("Error fetching destination schema: %v\n", err))
+	}
+
+	if pUtils.IsOCF(jsonData) {
+		if schemaType != pKgo.SchemaTypeAvro {
+			panic(fmt.Sprintf("Avro container files can only be loaded into an Avro destination, not %s", schemaType))
+		}
+		destinationCodec, hdr, destinationTopic := setupLoader()
+		records, events, err := pKgo.ConvertOCFToAvroKgoRecords(jsonData, destinationCodec, hdr, []byte("eventKey"), destinationTopic)
+		if err != nil {
+			slog.Error("Error converting OCF records", "Error", err)
+			return
+		}
+		setRecordFields(records, events)
+		pKgo.AddRecordHeaders(records, loadHeaders(schemaType, hdr, "")...)
+		submitRecords(records)
+		return
+	}
 
-	if eventType == nil {
-		slog.Error("Event type pointer is nil")
+	if schemaType == pKgo.SchemaTypeProtobuf {
+		descriptor, hdr, err := pKgo.FetchProtobufDestinationSchema(schemaURL, *messageName)
+		if err != nil {
+			panic(fmt.Sprintf("Error fetching destination schema: %v\n", err))
+		}
+		records, err := pKgo.ConvertToProtobufKgoRecords(jsonData, descriptor, hdr, []byte("eventKey"), destinationTopic)
+		if err != nil {
+			slog.Error("Error converting to Protobuf records", "Error", err)
+			return
+		}
+		setRecordFields(records, jsonData)
+		pKgo.AddRecordHeaders(records, loadHeaders(schemaType, hdr, string(descriptor.FullName()))...)
+		submitRecords(records)
 		return
 	}
 
+	if schemaType == pKgo.SchemaTypeJSON {
+		schema, hdr, err := pKgo.FetchJSONDestinationSchema(schemaURL)
+		if err != nil {
+			panic(fmt.Sprintf("Error fetching destination schema: %v\n", err))
+		}
+		records, err := pKgo.ConvertToJSONSchemaKgoRecords(jsonData, schema, hdr, []byte("eventKey"), destinationTopic)
+		if err != nil {
+			slog.Error("Error converting to JSON records", "Error", err)
+			return
+		}
+		setRecordFields(records, jsonData)
+		pKgo.AddRecordHeaders(records, loadHeaders(schemaType, hdr, "")...)
+		submitRecords(records)
+		return
+	}
+
+	destinationCodec, hdr, destinationTopic := setupLoader()
+
 	eventTypestr := *eventType
 
-	avroRecords, err = pKgo.ConvertToAvroKgoRecords(eventTypestr, jsonData, hdr, destinationCodec, []byte("eventKey"), nil, destinationTopic)
+	headers := pKgo.TransformHeaders(loadHeaders(schemaType, hdr, eventTypestr))
+	avroRecords, err = pKgo.ConvertToAvroKgoRecords(eventTypestr, jsonData, hdr, destinationCodec, []byte("eventKey"), headers, destinationTopic)
 	if err != nil {
 		slog.Error("Error converting to Avro records", "Error", err)
 		return
 	}
 
-	if len(avroRecords) > 0 {
-		err := pKgo.SubmitRecords(context.Background(), avroRecords, seeds)
-		if err != nil {
-			slog.Error("Error submitting records", "Error", err)
+	setRecordFields(avroRecords, jsonData)
+	submitRecords(avroRecords)
+}
+
+// setRecordFields sets the record keys, timestamps and partitions from the events. Keys are Avro encoded
+// when a key schema is registered for the topic, otherwise they are the value of the key field.
+func setRecordFields(records []*kgo.Record, jsonData []byte) {
+	fields := recordFields
+
+	keyCodec, keyHdr, err := pKgo.FetchAvroKeySchema(schemaURL, destinationTopic)
+	if err != nil {
+		panic(fmt.Sprintf("Error fetching key schema: %v\n", err))
+	}
+	if keyCodec != nil {
+		keyField := fields.Key
+		if keyField == "" {
+			keyField = defaultAvroKeyField
+		}
+		if err := pKgo.EncodeAvroKeys(records, jsonData, keyCodec, keyHdr, keyField); err != nil {
+			panic(fmt.Sprintf("Error encoding keys: %v\n", err))
 		}
-	} else {
+		fields.Key = ""
+	}
+
+	if err := pKgo.ApplyRecordFields(records, jsonData, fields); err != nil {
+		panic(fmt.Sprintf("Error setting record fields: %v\n", err))
+	}
+}
+
+// loadHeaders returns the headers added to every record of the load: the content type and schema ID of
+// the value, the event type when known, the source file and run ID, then the -H headers.
+func loadHeaders(schemaType string, hdr []byte, eventType string) []kgo.RecordHeader {
+	headers := []kgo.RecordHeader{{Key: pUtils.ContentTypeHeader, Value: []byte(pKgo.ContentType(schemaType))}}
+	if schemaID, _, err := pUtils.DecodeBuffer(hdr); err == nil {
+		headers = append(headers, kgo.RecordHeader{Key: pUtils.SchemaIDHeader, Value: []byte(strconv.Itoa(schemaID))})
+	}
+	if eventType != "" {
+		headers = append(headers, kgo.RecordHeader{Key: pUtils.EventTypeHeader, Value: []byte(eventType)})
+	}
+	headers = append(headers,
+		kgo.RecordHeader{Key: pUtils.SourceFileHeader, Value: []byte(sourceFile)},
+		kgo.RecordHeader{Key: pUtils.LoadRunIDHeader, Value: []byte(loadRunID)},
+	)
+	return append(headers, extraHeaders...)
+}
+
+// submitRecords produces the records and exits with status 1 when any of them could not be
+// produced, so scripts can tell a partial load from a complete one.
+func submitRecords(records []*kgo.Record) {
+	if len(records) == 0 {
 		slog.Info("No records to submit")
+		return
 	}
 
+	ctx := context.Background()
+	producer, err := pKgo.NewProducer(seeds, producerOptions)
+	if err != nil {
+		slog.Error("Error creating producer", "Error", err)
+		os.Exit(1)
+	}
+
+	results, err := producer.Produce(ctx, records)
+	if closeErr := producer.Close(ctx); closeErr != nil {
+		slog.Error("Error closing producer", "Error", closeErr)
+	}
+	if err != nil || len(results.Failed()) > 0 {
+		slog.Error("Error submitting records", "Error", err, "delivered", results.Delivered(), "failed", len(results.Failed()))
+		os.Exit(1)
+	}
 }
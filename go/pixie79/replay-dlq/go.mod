@@ -0,0 +1,3 @@
+module pixie79/replay-dlq
+
+go 1.22.4
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	pUtils "pixie79/utils"
+	pKgo "pixie79/utils/kgo"
+
+	"github.com/joho/godotenv"
+)
+
+func init() {
+
+	err := godotenv.Load()
+	if err != nil {
+		fmt.Printf("Not using .env file")
+	}
+
+	pUtils.SetupLogger()
+}
+
+func main() {
+	var (
+		dlqTopic = flag.String("dlq", os.Getenv("DLQ_TOPIC"), "Dead-letter topic to replay, defaults to DLQ_TOPIC")
+		topic    = flag.String("topic", "", "Topic to replay records to, defaults to the dlq.source_topic header of each record")
+		stage    = flag.String("stage", "", "Only replay records that failed at this stage (e.g. 'decode', 'encode')")
+		dryRun   = flag.Bool("dry-run", false, "Read and report the dead-lettered records without replaying them")
+		group    = flag.String("group", "replay-dlq", "Consumer group recording the replayed offsets, so a rerun only replays new records; empty replays the whole topic")
+		timeout  = flag.Duration("poll-timeout", 10*time.Second, "Longest wait for dead-lettered records before the replay fails")
+		size     = flag.Int("transaction-size", 1000, "Records replayed per transaction, each committing the group's offsets with it")
+	)
+	connectionFlags := pKgo.RegisterConnectionFlags(flag.CommandLine)
+	flag.Parse()
+
+	connection, err := connectionFlags.Config()
+	if err != nil {
+		panic(fmt.Sprintf("Invalid connection settings: %v", err))
+	}
+	pKgo.SetConnectionConfig(connection)
+
+	seeds := connection.Kafka.Brokers
+	if len(seeds) == 0 {
+		panic("Kafka brokers are required, set REDPANDA_SEED_URL, -brokers or use an rpk profile")
+	}
+
+	if *dlqTopic == "" {
+		panic("Dead-letter topic is required, set -dlq or DLQ_TOPIC")
+	}
+
+	stats, err := pKgo.ReplayDeadLetters(context.Background(), seeds, *dlqTopic, pKgo.ReplayOptions{
+		Topic:           *topic,
+		Stage:           *stage,
+		DryRun:          *dryRun,
+		Group:           *group,
+		PollTimeout:     *timeout,
+		TransactionSize: *size,
+	})
+	if err != nil {
+		slog.Error("Error replaying dead-lettered records", "Error", err)
+		os.Exit(1)
+	}
+	slog.Info("Replayed dead-lettered records", "topic", *dlqTopic, "read", stats.Read, "replayed", stats.Replayed, "skipped", stats.Skipped)
+}
@@ -0,0 +1,52 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// avroValue asserts that a goavro native value found at path has the Go type T.
+func avroValue[T any](native interface{}, path string) (T, error) {
+	value, ok := native.(T)
+	if !ok {
+		var zero T
+		return zero, fmt.Errorf("%s: expected %T, got %T", displayAvroPath(path), zero, native)
+	}
+	return value, nil
+}
+
+// avroBranch returns the value of a goavro native union written with branch, or nil when the union is null.
+func avroBranch(native interface{}, branch string, path string) (interface{}, error) {
+	if native == nil {
+		return nil, nil
+	}
+	wrapped, ok := native.(map[string]interface{})
+	if !ok || len(wrapped) != 1 {
+		return nil, fmt.Errorf("%s: expected a union value, got %T", displayAvroPath(path), native)
+	}
+	value, ok := wrapped[branch]
+	if !ok {
+		for name := range wrapped {
+			return nil, fmt.Errorf("%s: unexpected union branch %s", displayAvroPath(path), name)
+		}
+	}
+	return value, nil
+}
+
+func joinAvroPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func indexAvroPath(path string, index int) string {
+	return path + "[" + strconv.Itoa(index) + "]"
+}
+
+func displayAvroPath(path string) string {
+	if path == "" {
+		return "record"
+	}
+	return path
+}
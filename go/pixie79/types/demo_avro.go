@@ -0,0 +1,410 @@
+// Code generated by avrogen from demo.avsc. DO NOT EDIT.
+
+package types
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+)
+
+// CustomerEvent is generated from the Avro record com.demo.event.v1.CustomerEvent.
+type CustomerEvent struct {
+	Metadata EventMetadata `json:"metadata" avro:"metadata"`
+	Payload  Customer      `json:"payload" avro:"payload"`
+}
+
+// ToAvro converts the record to goavro native data.
+func (r CustomerEvent) ToAvro() map[string]interface{} {
+	return map[string]interface{}{
+		"metadata": r.Metadata.ToAvro(),
+		"payload":  r.Payload.ToAvro(),
+	}
+}
+
+// FromAvro fills the record from goavro native data.
+func (r *CustomerEvent) FromAvro(native map[string]interface{}) error {
+	return r.fromAvro(native, "")
+}
+
+func (r *CustomerEvent) fromAvro(native map[string]interface{}, path string) error {
+	{
+		record, err := avroValue[map[string]interface{}](native["metadata"], joinAvroPath(path, "metadata"))
+		if err != nil {
+			return err
+		}
+		if err := r.Metadata.fromAvro(record, joinAvroPath(path, "metadata")); err != nil {
+			return err
+		}
+	}
+	{
+		record, err := avroValue[map[string]interface{}](native["payload"], joinAvroPath(path, "payload"))
+		if err != nil {
+			return err
+		}
+		if err := r.Payload.fromAvro(record, joinAvroPath(path, "payload")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EventMetadata is generated from the Avro record com.demo.event.v1.EventMetadata.
+type EventMetadata struct {
+	MessageKey          string    `json:"message_key" avro:"message_key"`
+	CreatedDate         CustpTime `json:"created_date" avro:"created_date"`
+	UpdatedDate         CustpTime `json:"updated_date" avro:"updated_date"`
+	OutboxPublishedDate CustpTime `json:"outbox_published_date" avro:"outbox_published_date"`
+	EventType           string    `json:"event_type" avro:"event_type"`
+}
+
+// ToAvro converts the record to goavro native data.
+func (r EventMetadata) ToAvro() map[string]interface{} {
+	return map[string]interface{}{
+		"message_key":           r.MessageKey,
+		"created_date":          r.CreatedDate.Time,
+		"updated_date":          r.UpdatedDate.Time,
+		"outbox_published_date": r.OutboxPublishedDate.Time,
+		"event_type":            r.EventType,
+	}
+}
+
+// FromAvro fills the record from goavro native data.
+func (r *EventMetadata) FromAvro(native map[string]interface{}) error {
+	return r.fromAvro(native, "")
+}
+
+func (r *EventMetadata) fromAvro(native map[string]interface{}, path string) error {
+	{
+		value, err := avroValue[string](native["message_key"], joinAvroPath(path, "message_key"))
+		if err != nil {
+			return err
+		}
+		r.MessageKey = value
+	}
+	{
+		value, err := avroValue[time.Time](native["created_date"], joinAvroPath(path, "created_date"))
+		if err != nil {
+			return err
+		}
+		r.CreatedDate = CustpTime{Time: value}
+	}
+	{
+		value, err := avroValue[time.Time](native["updated_date"], joinAvroPath(path, "updated_date"))
+		if err != nil {
+			return err
+		}
+		r.UpdatedDate = CustpTime{Time: value}
+	}
+	{
+		value, err := avroValue[time.Time](native["outbox_published_date"], joinAvroPath(path, "outbox_published_date"))
+		if err != nil {
+			return err
+		}
+		r.OutboxPublishedDate = CustpTime{Time: value}
+	}
+	{
+		value, err := avroValue[string](native["event_type"], joinAvroPath(path, "event_type"))
+		if err != nil {
+			return err
+		}
+		r.EventType = value
+	}
+	return nil
+}
+
+// Customer is generated from the Avro record com.demo.event.v1.Customer.
+type Customer struct {
+	Id                 string     `json:"id" avro:"id"`
+	Title              *string    `json:"title,omitempty" avro:"title"`
+	PreferredName      *string    `json:"preferred_name,omitempty" avro:"preferred_name"`
+	GivenName          *string    `json:"given_name,omitempty" avro:"given_name"`
+	LastName           *string    `json:"last_name,omitempty" avro:"last_name"`
+	MiddleName         *string    `json:"middle_name,omitempty" avro:"middle_name"`
+	DateOfBirth        *CustpTime `json:"date_of_birth,omitempty" avro:"date_of_birth"`
+	DateOfDeath        *CustpTime `json:"date_of_death,omitempty" avro:"date_of_death"`
+	Gender             *string    `json:"gender,omitempty" avro:"gender"`
+	PlaceOfBirth       *string    `json:"place_of_birth,omitempty" avro:"place_of_birth"`
+	CountryOfResidence *string    `json:"country_of_residence,omitempty" avro:"country_of_residence"`
+}
+
+// ToAvro converts the record to goavro native data.
+func (r Customer) ToAvro() map[string]interface{} {
+	return map[string]interface{}{
+		"id": r.Id,
+		"title": func() interface{} {
+			if r.Title == nil {
+				return nil
+			}
+			return map[string]interface{}{"string": (*r.Title)}
+		}(),
+		"preferred_name": func() interface{} {
+			if r.PreferredName == nil {
+				return nil
+			}
+			return map[string]interface{}{"string": (*r.PreferredName)}
+		}(),
+		"given_name": func() interface{} {
+			if r.GivenName == nil {
+				return nil
+			}
+			return map[string]interface{}{"string": (*r.GivenName)}
+		}(),
+		"last_name": func() interface{} {
+			if r.LastName == nil {
+				return nil
+			}
+			return map[string]interface{}{"string": (*r.LastName)}
+		}(),
+		"middle_name": func() interface{} {
+			if r.MiddleName == nil {
+				return nil
+			}
+			return map[string]interface{}{"string": (*r.MiddleName)}
+		}(),
+		"date_of_birth": func() interface{} {
+			if r.DateOfBirth == nil {
+				return nil
+			}
+			return map[string]interface{}{"int.date": (*r.DateOfBirth).Time}
+		}(),
+		"date_of_death": func() interface{} {
+			if r.DateOfDeath == nil {
+				return nil
+			}
+			return map[string]interface{}{"int.date": (*r.DateOfDeath).Time}
+		}(),
+		"gender": func() interface{} {
+			if r.Gender == nil {
+				return nil
+			}
+			return map[string]interface{}{"string": (*r.Gender)}
+		}(),
+		"place_of_birth": func() interface{} {
+			if r.PlaceOfBirth == nil {
+				return nil
+			}
+			return map[string]interface{}{"string": (*r.PlaceOfBirth)}
+		}(),
+		"country_of_residence": func() interface{} {
+			if r.CountryOfResidence == nil {
+				return nil
+			}
+			return map[string]interface{}{"string": (*r.CountryOfResidence)}
+		}(),
+	}
+}
+
+// FromAvro fills the record from goavro native data.
+func (r *Customer) FromAvro(native map[string]interface{}) error {
+	return r.fromAvro(native, "")
+}
+
+func (r *Customer) fromAvro(native map[string]interface{}, path string) error {
+	{
+		value, err := avroValue[string](native["id"], joinAvroPath(path, "id"))
+		if err != nil {
+			return err
+		}
+		r.Id = value
+	}
+	{
+		value0, err := avroBranch(native["title"], "string", joinAvroPath(path, "title"))
+		if err != nil {
+			return err
+		}
+		r.Title = nil
+		if value0 != nil {
+			var elem0 string
+			{
+				value, err := avroValue[string](value0, joinAvroPath(path, "title"))
+				if err != nil {
+					return err
+				}
+				elem0 = value
+			}
+			r.Title = &elem0
+		}
+	}
+	{
+		value0, err := avroBranch(native["preferred_name"], "string", joinAvroPath(path, "preferred_name"))
+		if err != nil {
+			return err
+		}
+		r.PreferredName = nil
+		if value0 != nil {
+			var elem0 string
+			{
+				value, err := avroValue[string](value0, joinAvroPath(path, "preferred_name"))
+				if err != nil {
+					return err
+				}
+				elem0 = value
+			}
+			r.PreferredName = &elem0
+		}
+	}
+	{
+		value0, err := avroBranch(native["given_name"], "string", joinAvroPath(path, "given_name"))
+		if err != nil {
+			return err
+		}
+		r.GivenName = nil
+		if value0 != nil {
+			var elem0 string
+			{
+				value, err := avroValue[string](value0, joinAvroPath(path, "given_name"))
+				if err != nil {
+					return err
+				}
+				elem0 = value
+			}
+			r.GivenName = &elem0
+		}
+	}
+	{
+		value0, err := avroBranch(native["last_name"], "string", joinAvroPath(path, "last_name"))
+		if err != nil {
+			return err
+		}
+		r.LastName = nil
+		if value0 != nil {
+			var elem0 string
+			{
+				value, err := avroValue[string](value0, joinAvroPath(path, "last_name"))
+				if err != nil {
+					return err
+				}
+				elem0 = value
+			}
+			r.LastName = &elem0
+		}
+	}
+	{
+		value0, err := avroBranch(native["middle_name"], "string", joinAvroPath(path, "middle_name"))
+		if err != nil {
+			return err
+		}
+		r.MiddleName = nil
+		if value0 != nil {
+			var elem0 string
+			{
+				value, err := avroValue[string](value0, joinAvroPath(path, "middle_name"))
+				if err != nil {
+					return err
+				}
+				elem0 = value
+			}
+			r.MiddleName = &elem0
+		}
+	}
+	{
+		value0, err := avroBranch(native["date_of_birth"], "int.date", joinAvroPath(path, "date_of_birth"))
+		if err != nil {
+			return err
+		}
+		r.DateOfBirth = nil
+		if value0 != nil {
+			var elem0 CustpTime
+			{
+				value, err := avroValue[time.Time](value0, joinAvroPath(path, "date_of_birth"))
+				if err != nil {
+					return err
+				}
+				elem0 = CustpTime{Time: value}
+			}
+			r.DateOfBirth = &elem0
+		}
+	}
+	{
+		value0, err := avroBranch(native["date_of_death"], "int.date", joinAvroPath(path, "date_of_death"))
+		if err != nil {
+			return err
+		}
+		r.DateOfDeath = nil
+		if value0 != nil {
+			var elem0 CustpTime
+			{
+				value, err := avroValue[time.Time](value0, joinAvroPath(path, "date_of_death"))
+				if err != nil {
+					return err
+				}
+				elem0 = CustpTime{Time: value}
+			}
+			r.DateOfDeath = &elem0
+		}
+	}
+	{
+		value0, err := avroBranch(native["gender"], "string", joinAvroPath(path, "gender"))
+		if err != nil {
+			return err
+		}
+		r.Gender = nil
+		if value0 != nil {
+			var elem0 string
+			{
+				value, err := avroValue[string](value0, joinAvroPath(path, "gender"))
+				if err != nil {
+					return err
+				}
+				elem0 = value
+			}
+			r.Gender = &elem0
+		}
+	}
+	{
+		value0, err := avroBranch(native["place_of_birth"], "string", joinAvroPath(path, "place_of_birth"))
+		if err != nil {
+			return err
+		}
+		r.PlaceOfBirth = nil
+		if value0 != nil {
+			var elem0 string
+			{
+				value, err := avroValue[string](value0, joinAvroPath(path, "place_of_birth"))
+				if err != nil {
+					return err
+				}
+				elem0 = value
+			}
+			r.PlaceOfBirth = &elem0
+		}
+	}
+	{
+		value0, err := avroBranch(native["country_of_residence"], "string", joinAvroPath(path, "country_of_residence"))
+		if err != nil {
+			return err
+		}
+		r.CountryOfResidence = nil
+		if value0 != nil {
+			var elem0 string
+			{
+				value, err := avroValue[string](value0, joinAvroPath(path, "country_of_residence"))
+				if err != nil {
+					return err
+				}
+				elem0 = value
+			}
+			r.CountryOfResidence = &elem0
+		}
+	}
+	return nil
+}
+
+const customerEventAvroSchema = "{\"type\":\"record\",\"name\":\"CustomerEvent\",\"namespace\":\"com.demo.event.v1\",\"fields\":[{\"name\":\"metadata\",\"type\":{\"type\":\"record\",\"name\":\"EventMetadata\",\"doc\":\"Represents common event meta-data of interest\",\"fields\":[{\"name\":\"message_key\",\"type\":\"string\",\"doc\":\"Denotes the unique key for the message from this topic\"},{\"name\":\"created_date\",\"type\":{\"type\":\"long\",\"logicalType\":\"timestamp-millis\"},\"doc\":\"The service timestamp value associated with the commit point in the platform database giving rise to the INSERT operation recorded in 'milliseconds since epoch' format\"},{\"name\":\"updated_date\",\"type\":{\"type\":\"long\",\"logicalType\":\"timestamp-millis\"},\"doc\":\"The service timestamp value associated with the commit point in the platform database giving rise to the UPDATE or INSERT operation recorded in 'milliseconds since epoch' format\"},{\"name\":\"outbox_published_date\",\"type\":{\"type\":\"long\",\"logicalType\":\"timestamp-millis\"},\"doc\":\"Timestamp value associated with the commit point in the platform when the message was committed to the service outbox pattern prior to publication to Kafka\"},{\"name\":\"event_type\",\"type\":\"string\",\"doc\":\"The field denotes whether the message relates to an INSERT event for the primary key of the 'top-level entity' of the topic being mastered in the system of record or is otherwise an UPDATE or DELETE event\"}]}},{\"name\":\"payload\",\"type\":{\"type\":\"record\",\"name\":\"Customer\",\"fields\":[{\"name\":\"id\",\"type\":\"string\",\"doc\":\"The unique key that has been internally assigned to the party\"},{\"name\":\"title\",\"type\":[\"null\",\"string\"],\"doc\":\"ENUM - Title for the party. DOCT - Doctor, MIST - Mr, MISS - Miss, MADM - Madame\",\"default\":null},{\"name\":\"preferred_name\",\"type\":[\"null\",\"string\"],\"doc\":\"Name which party has indicated is their preferred name\",\"default\":null},{\"name\":\"given_name\",\"type\":[\"null\",\"string\"],\"doc\":\"Party’s first name\",\"default\":null},{\"name\":\"last_name\",\"type\":[\"null\",\"string\"],\"doc\":\"Party’s surname\",\"default\":null},{\"name\":\"middle_name\",\"type\":[\"null\",\"string\"],\"doc\":\"Party’s middle name\",\"default\":null},{\"name\":\"date_of_birth\",\"type\":[\"null\",{\"type\":\"int\",\"logicalType\":\"date\"}],\"doc\":\"Date party was born\",\"default\":null},{\"name\":\"date_of_death\",\"type\":[\"null\",{\"type\":\"int\",\"logicalType\":\"date\"}],\"doc\":\"Date party passed away\",\"default\":null},{\"name\":\"gender\",\"type\":[\"null\",\"string\"],\"doc\":\"ENUM - Gender of the party. MALE - male, FEMALE - female, NA - not declared\",\"default\":null},{\"name\":\"place_of_birth\",\"type\":[\"null\",\"string\"],\"doc\":\"Location party was born\",\"default\":null},{\"name\":\"country_of_residence\",\"type\":[\"null\",\"string\"],\"doc\":\"Country of party residence\",\"default\":null}]}}]}"
+
+func init() {
+	RegisterEventType(EventType{
+		Name:     "customerEvent",
+		AvroName: "com.demo.event.v1.CustomerEvent",
+		Schema:   customerEventAvroSchema,
+		Type:     reflect.TypeOf(CustomerEvent{}),
+		Convert: func(jsonData []byte) (map[string]interface{}, error) {
+			var event CustomerEvent
+			if err := json.Unmarshal(jsonData, &event); err != nil {
+				return nil, err
+			}
+			return event.ToAvro(), nil
+		},
+	})
+}
@@ -0,0 +1,3 @@
+package types
+
+//go:generate go run pixie79/avrogen -schema ../../../schemas/demo.avsc -o demo_avro.go
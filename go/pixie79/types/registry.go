@@ -0,0 +1,67 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// EventType describes an event type the test data tools can generate and convert to Avro.
+type EventType struct {
+	// Name selects the event type on the command line
+	Name string
+	// AvroName is the full name of the Avro record the type converts to
+	AvroName string
+	// Schema is the Avro schema the type was generated from, empty for hand-written types
+	Schema string
+	// Type is the Go type a single JSON event decodes into
+	Type reflect.Type
+	// Convert decodes a single JSON event and converts it to goavro native data. When nil, events
+	// are decoded into Type and converted from its avro struct tags against the destination schema.
+	Convert func(jsonData []byte) (map[string]interface{}, error)
+	// Generate returns a random event of Type for test data, picking names from customers. It is
+	// nil for types the generator has no test data for.
+	Generate func(customers []TestCustomer) interface{}
+}
+
+var eventTypes = map[string]EventType{}
+
+// RegisterEventType adds an event type to the registry. Registering the same name twice panics, as
+// it can only happen when two packages claim the same event type.
+func RegisterEventType(eventType EventType) {
+	if _, exists := eventTypes[eventType.Name]; exists {
+		panic(fmt.Sprintf("event type %s registered twice", eventType.Name))
+	}
+	eventTypes[eventType.Name] = eventType
+}
+
+// RegisterEventGenerator sets the test data generator of a registered event type, for generators
+// that live outside the package registering the type. It panics when the type is not registered or
+// already has a generator.
+func RegisterEventGenerator(name string, generate func(customers []TestCustomer) interface{}) {
+	eventType, exists := eventTypes[name]
+	if !exists {
+		panic(fmt.Sprintf("generator registered for unknown event type %s", name))
+	}
+	if eventType.Generate != nil {
+		panic(fmt.Sprintf("event type %s has a generator already", name))
+	}
+	eventType.Generate = generate
+	eventTypes[name] = eventType
+}
+
+// LookupEventType returns the registered event type with the given name.
+func LookupEventType(name string) (EventType, bool) {
+	eventType, ok := eventTypes[name]
+	return eventType, ok
+}
+
+// EventTypeNames returns the names of every registered event type in sorted order.
+func EventTypeNames() []string {
+	names := make([]string, 0, len(eventTypes))
+	for name := range eventTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
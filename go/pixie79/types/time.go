@@ -57,13 +57,3 @@ func (ct *CustpTime) ToTimePointer() *time.Time {
 	}
 	return &ct.Time
 }
-
-func serializeDate(record *int) interface{} {
-	if record == nil {
-		return nil // Return nil if the pointer is nil
-	}
-	daysSinceEpoch := *record
-	epoch := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC) // Epoch reference
-	date := epoch.AddDate(0, 0, daysSinceEpoch)          // Add the number of days to the epoch
-	return map[string]interface{}{"int.date": date}
-}
@@ -1,47 +1,45 @@
 package utils
 
 import (
+	"errors"
 	"log/slog"
-	"strings"
 
 	goavro "github.com/linkedin/goavro/v2"
 )
 
-// decodeAvro decodes an Avro event using the provided schema and returns a nested map[string]interface{}.
+// DecodeAvro decodes a binary Avro payload using the provided schema and returns a nested map[string]interface{}.
 //
 // Parameters:
 // - schema: The Avro schema used for decoding the event (string).
-// - event: The Avro event to be decoded ([]byte).
+// - payload: The binary Avro payload with the wire-format header already removed ([]byte).
 //
 // Returns:
 // - nestedMap: The decoded event as a nested map[string]interface{}.
-func DecodeAvro(schema string, event []byte) map[string]interface{} {
+// - error: An error if the codec cannot be built or the payload cannot be decoded.
+func DecodeAvro(schema string, payload []byte) (map[string]interface{}, error) {
 	sourceCodec, err := goavro.NewCodec(schema)
 	if err != nil {
 		slog.Error("Error creating Avro codec", "Error", err)
-		return nil
-	}
-
-	eventStr := strings.Replace(string(event), "\"", "", -1)
-	decodedEvent, err := b64DecodeMsg(eventStr, 5)
-	if err != nil {
-		slog.Error("Error decoding base64", "Error", err)
-		return nil
+		return nil, err
 	}
+	return DecodeAvroWithCodec(sourceCodec, payload)
+}
 
-	native, _, err := sourceCodec.NativeFromBinary(decodedEvent)
+// DecodeAvroWithCodec decodes a binary Avro payload with an already compiled codec.
+func DecodeAvroWithCodec(codec *goavro.Codec, payload []byte) (map[string]interface{}, error) {
+	native, _, err := codec.NativeFromBinary(payload)
 	if err != nil {
 		slog.Error("Error creating native from binary", "Error", err)
-		return nil
+		return nil, err
 	}
 
 	nestedMap, ok := native.(map[string]interface{})
 	if !ok {
 		slog.Error("Unable to convert native to map[string]interface{}")
-		return nil
+		return nil, errors.New("decoded Avro value is not a record")
 	}
 
-	return nestedMap
+	return nestedMap, nil
 }
 
 func WrapUnionSimple(value interface{}, typeName string) interface{} {
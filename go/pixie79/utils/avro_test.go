@@ -0,0 +1,131 @@
+package utils_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"pixie79/utils"
+
+	goavro "github.com/linkedin/goavro/v2"
+	. "github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+)
+
+const demoSchemaFile = "../../../schemas/demo.avsc"
+
+// fataler is the subset of testing.TB shared with GinkgoT.
+type fataler interface {
+	Fatalf(format string, args ...interface{})
+}
+
+var demoNative = map[string]interface{}{
+	"metadata": map[string]interface{}{
+		"message_key":           "tnKGDKUndl",
+		"created_date":          int64(1296997036167),
+		"updated_date":          int64(693745893153),
+		"outbox_published_date": int64(1203458653655),
+		"event_type":            "INSERT",
+	},
+	"payload": map[string]interface{}{
+		"id":                   "PKs-Is7j",
+		"given_name":           map[string]interface{}{"string": "Tom"},
+		"last_name":            map[string]interface{}{"string": "Jones"},
+		"country_of_residence": map[string]interface{}{"string": "UK"},
+	},
+}
+
+// demoCodec compiles the demo schema shipped with the repository.
+func demoCodec(tb fataler) *goavro.Codec {
+	schema, err := os.ReadFile(demoSchemaFile)
+	if err != nil {
+		tb.Fatalf("failed to read schema: %v", err)
+	}
+	codec, err := goavro.NewCodec(string(schema))
+	if err != nil {
+		tb.Fatalf("failed to compile schema: %v", err)
+	}
+	return codec
+}
+
+// demoWireValue returns the demo record encoded in schema registry wire format.
+func demoWireValue(tb fataler, codec *goavro.Codec) []byte {
+	value, err := codec.BinaryFromNative(utils.EncodeBuffer(42), demoNative)
+	if err != nil {
+		tb.Fatalf("failed to encode record: %v", err)
+	}
+	return value
+}
+
+var _ = Describe("Wire format", func() {
+	It("should round trip the schema ID through EncodeBuffer and DecodeBuffer", func() {
+		value := append(utils.EncodeBuffer(1234), 0x02, 0x04)
+		id, payload, err := utils.DecodeBuffer(value)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(id).To(gomega.Equal(1234))
+		gomega.Expect(payload).To(gomega.Equal([]byte{0x02, 0x04}))
+	})
+
+	It("should reject a bad magic byte", func() {
+		_, _, err := utils.DecodeBuffer([]byte{1, 0, 0, 0, 1, 2})
+		gomega.Expect(err).To(gomega.MatchError(utils.ErrInvalidWireFormat))
+	})
+
+	It("should reject a truncated header", func() {
+		_, _, err := utils.DecodeBuffer([]byte{0, 0, 1})
+		gomega.Expect(err).To(gomega.MatchError(utils.ErrInvalidWireFormat))
+	})
+
+	It("should decode a wire-format record", func() {
+		codec := demoCodec(GinkgoT())
+		_, payload, err := utils.DecodeBuffer(demoWireValue(GinkgoT(), codec))
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		nestedMap, err := utils.DecodeAvroWithCodec(codec, payload)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		lastName, _ := utils.GetStringField(nestedMap, "payload.last_name")
+		gomega.Expect(lastName).To(gomega.Equal("Jones"))
+	})
+})
+
+// BenchmarkDecodeJSONBase64 measures the previous decode path, which JSON-marshalled the
+// value into a base64 string before stripping the quotes, decoding it and slicing off the header.
+func BenchmarkDecodeJSONBase64(b *testing.B) {
+	codec := demoCodec(b)
+	value := demoWireValue(b, codec)
+	b.SetBytes(int64(len(value)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			b.Fatal(err)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(strings.Replace(string(raw), "\"", "", -1))
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := utils.DecodeAvroWithCodec(codec, decoded[5:]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkDecodeWireFormat measures decoding straight from the record value.
+func BenchmarkDecodeWireFormat(b *testing.B) {
+	codec := demoCodec(b)
+	value := demoWireValue(b, codec)
+	b.SetBytes(int64(len(value)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, payload, err := utils.DecodeBuffer(value)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := utils.DecodeAvroWithCodec(codec, payload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
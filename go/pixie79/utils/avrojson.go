@@ -0,0 +1,350 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+const secondsPerDay = 86400
+
+// avroTimeLayouts are the string formats accepted for date and timestamp fields.
+var avroTimeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"}
+
+// AvroNativeFromJSON converts a JSON document into goavro native data for the schema, without a Go
+// type for the event. See AvroNativeFromJSONValue for how values are converted.
+func AvroNativeFromJSON(schema *AvroSchema, data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("unable to parse JSON: %w", err)
+	}
+	return AvroNativeFromJSONValue(schema, value)
+}
+
+// AvroNativeFromJSONValue converts decoded JSON into goavro native data for the schema. Record fields
+// missing from the JSON take their default, or null when the field is a nullable union. Union values
+// may be plain or wrapped as {"branch": value}, and take the first branch they convert to.
+//
+// Dates and timestamps accept RFC 3339 strings, plain dates, or numbers read as CustpTime reads them:
+// numbers above 1e10 are milliseconds since the epoch and smaller ones days. timestamp-micros numbers
+// are microseconds. Decimals accept numbers or numeric strings, and bytes and fixed values strings
+// whose code points 0-255 are the bytes. Errors name the path of the offending field.
+func AvroNativeFromJSONValue(schema *AvroSchema, value interface{}) (interface{}, error) {
+	return nativeFromJSON(schema, value, "")
+}
+
+func nativeFromJSON(schema *AvroSchema, value interface{}, path string) (interface{}, error) {
+	if f, ok := value.(float64); ok {
+		value = json.Number(strconv.FormatFloat(f, 'f', -1, 64))
+	}
+
+	switch schema.Type {
+	case "union":
+		return unionFromJSON(schema, value, path)
+	case "null":
+		if value != nil {
+			return nil, jsonTypeError(value, schema, path)
+		}
+		return nil, nil
+	}
+
+	if value == nil {
+		return nil, fmt.Errorf("%s: null is not a valid %s", displayPath(path), schema.BranchName())
+	}
+
+	switch schema.Type {
+	case "boolean":
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+	case "int", "long":
+		return integerFromJSON(schema, value, path)
+	case "float", "double":
+		number, ok := value.(json.Number)
+		if !ok {
+			break
+		}
+		f, err := number.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", displayPath(path), err)
+		}
+		if schema.Type == "float" {
+			return float32(f), nil
+		}
+		return f, nil
+	case "string":
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+	case "enum":
+		s, ok := value.(string)
+		if !ok {
+			break
+		}
+		for _, symbol := range schema.Symbols {
+			if symbol == s {
+				return s, nil
+			}
+		}
+		return nil, fmt.Errorf("%s: %s is not a symbol of enum %s", displayPath(path), s, schema.Name)
+	case "bytes", "fixed":
+		return bytesFromJSON(schema, value, path)
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			break
+		}
+		converted := make([]interface{}, len(items))
+		for i, item := range items {
+			c, err := nativeFromJSON(schema.Items, item, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			converted[i] = c
+		}
+		return converted, nil
+	case "map":
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			break
+		}
+		converted := make(map[string]interface{}, len(values))
+		for key, v := range values {
+			c, err := nativeFromJSON(schema.Values, v, joinPath(path, key))
+			if err != nil {
+				return nil, err
+			}
+			converted[key] = c
+		}
+		return converted, nil
+	case "record", "error":
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			break
+		}
+		return recordFromJSON(schema, values, path)
+	default:
+		return nil, fmt.Errorf("%s: unsupported Avro type %s", displayPath(path), schema.Type)
+	}
+	return nil, jsonTypeError(value, schema, path)
+}
+
+func recordFromJSON(schema *AvroSchema, values map[string]interface{}, path string) (interface{}, error) {
+	record := make(map[string]interface{}, len(schema.Fields))
+	for _, field := range schema.Fields {
+		fieldPath := joinPath(path, field.Name)
+		value, ok := values[field.Name]
+		for _, alias := range field.Aliases {
+			if ok {
+				break
+			}
+			value, ok = values[alias]
+		}
+
+		if !ok {
+			switch {
+			case field.HasDefault:
+				c, err := AvroDefault(field.Type, field.Default)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", displayPath(fieldPath), err)
+				}
+				record[field.Name] = c
+			case isNullable(field.Type):
+				record[field.Name] = nil
+			default:
+				return nil, fmt.Errorf("%s: missing required field", displayPath(fieldPath))
+			}
+			continue
+		}
+
+		c, err := nativeFromJSON(field.Type, value, fieldPath)
+		if err != nil {
+			return nil, err
+		}
+		record[field.Name] = c
+	}
+	return record, nil
+}
+
+func unionFromJSON(union *AvroSchema, value interface{}, path string) (interface{}, error) {
+	if value == nil {
+		if isNullable(union) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%s: null for a union without a null branch", displayPath(path))
+	}
+
+	// Avro's JSON encoding wraps union values in an object keyed by the branch
+	if wrapped, ok := value.(map[string]interface{}); ok && len(wrapped) == 1 {
+		for name, inner := range wrapped {
+			for _, branch := range union.Branches {
+				if name != branch.BranchName() && name != branch.Type && !(branch.isNamed() && name == unqualifiedName(branch.Name)) {
+					continue
+				}
+				c, err := nativeFromJSON(branch, inner, path)
+				if err != nil {
+					return nil, err
+				}
+				if branch.Type == "null" {
+					return nil, nil
+				}
+				return map[string]interface{}{branch.BranchName(): c}, nil
+			}
+		}
+	}
+
+	var (
+		candidates int
+		lastErr    error
+	)
+	for _, branch := range union.Branches {
+		if branch.Type == "null" {
+			continue
+		}
+		candidates++
+		c, err := nativeFromJSON(branch, value, path)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return map[string]interface{}{branch.BranchName(): c}, nil
+	}
+	// A nullable field has one branch to blame, so report its error
+	if candidates == 1 {
+		return nil, lastErr
+	}
+	return nil, fmt.Errorf("%s: no branch of the union accepts %s", displayPath(path), jsonKind(value))
+}
+
+func integerFromJSON(schema *AvroSchema, value interface{}, path string) (interface{}, error) {
+	switch schema.Logical {
+	case "date", "timestamp-millis", "timestamp-micros":
+		return timeFromJSON(schema, value, path)
+	}
+	number, ok := value.(json.Number)
+	if !ok {
+		return nil, jsonTypeError(value, schema, path)
+	}
+	i, err := number.Int64()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s is not an integer", displayPath(path), number)
+	}
+
+	switch schema.Logical {
+	case "time-millis":
+		return time.Duration(i) * time.Millisecond, nil
+	case "time-micros":
+		return time.Duration(i) * time.Microsecond, nil
+	}
+	if schema.Type == "long" {
+		return i, nil
+	}
+	if i < math.MinInt32 || i > math.MaxInt32 {
+		return nil, fmt.Errorf("%s: %d overflows int", displayPath(path), i)
+	}
+	return int32(i), nil
+}
+
+func timeFromJSON(schema *AvroSchema, value interface{}, path string) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		for _, layout := range avroTimeLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t.UTC(), nil
+			}
+		}
+		return nil, fmt.Errorf("%s: %q is not a date or RFC 3339 timestamp", displayPath(path), v)
+	case json.Number:
+		i, err := v.Int64()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s is not an integer", displayPath(path), v)
+		}
+		switch {
+		case schema.Logical == "timestamp-micros":
+			return time.UnixMicro(i).UTC(), nil
+		case i > 1e10 || i < -1e10:
+			return time.UnixMilli(i).UTC(), nil
+		default:
+			return time.Unix(i*secondsPerDay, 0).UTC(), nil
+		}
+	}
+	return nil, jsonTypeError(value, schema, path)
+}
+
+func bytesFromJSON(schema *AvroSchema, value interface{}, path string) (interface{}, error) {
+	if schema.Logical == "decimal" {
+		var text string
+		switch v := value.(type) {
+		case json.Number:
+			text = v.String()
+		case string:
+			text = v
+		default:
+			return nil, jsonTypeError(value, schema, path)
+		}
+		r, ok := new(big.Rat).SetString(text)
+		if !ok {
+			return nil, fmt.Errorf("%s: %q is not a decimal", displayPath(path), text)
+		}
+		return r, nil
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return nil, jsonTypeError(value, schema, path)
+	}
+	var b bytes.Buffer
+	for _, r := range s {
+		if r > 0xFF {
+			return nil, fmt.Errorf("%s: %q has characters outside 0-255 and is not Avro JSON bytes", displayPath(path), s)
+		}
+		b.WriteByte(byte(r))
+	}
+	if schema.Type == "fixed" && b.Len() != schema.Size {
+		return nil, fmt.Errorf("%s: %d bytes for fixed %s of size %d", displayPath(path), b.Len(), schema.Name, schema.Size)
+	}
+	return b.Bytes(), nil
+}
+
+// isNullable reports whether the schema is a union with a null branch.
+func isNullable(schema *AvroSchema) bool {
+	if schema.Type != "union" {
+		return false
+	}
+	for _, branch := range schema.Branches {
+		if branch.Type == "null" {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonTypeError(value interface{}, schema *AvroSchema, path string) error {
+	return fmt.Errorf("%s: %s cannot be written as %s", displayPath(path), jsonKind(value), schema.BranchName())
+}
+
+// jsonKind names the JSON type of a decoded value for error messages.
+func jsonKind(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number, float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
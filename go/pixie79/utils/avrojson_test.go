@@ -0,0 +1,107 @@
+package utils_test
+
+import (
+	"math/big"
+	"os"
+	"time"
+
+	"pixie79/utils"
+
+	goavro "github.com/linkedin/goavro/v2"
+	. "github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+)
+
+var _ = Describe("Schema-driven JSON conversion", func() {
+	const invoiceSchema = `{"type": "record", "name": "Invoice", "namespace": "com.demo", "fields": [
+		{"name": "id", "type": "long"},
+		{"name": "issued", "type": {"type": "int", "logicalType": "date"}},
+		{"name": "paid", "type": ["null", {"type": "long", "logicalType": "timestamp-millis"}], "default": null},
+		{"name": "total", "type": {"type": "bytes", "logicalType": "decimal", "precision": 9, "scale": 2}},
+		{"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["OPEN", "PAID"]}, "default": "OPEN"},
+		{"name": "lines", "type": {"type": "array", "items": {"type": "record", "name": "Line", "fields": [
+			{"name": "sku", "type": "string"},
+			{"name": "qty", "type": "int", "aliases": ["quantity"]}
+		]}}},
+		{"name": "note", "type": ["null", "string"]},
+		{"name": "ref", "type": ["null", "long", "string"], "default": null}
+	]}`
+
+	parse := func(source string) *utils.AvroSchema {
+		schema, err := utils.ParseAvroSchema(source)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		return schema
+	}
+
+	It("should convert JSON the codec accepts, filling defaults and nullable fields", func() {
+		native, err := utils.AvroNativeFromJSON(parse(invoiceSchema), []byte(`{
+			"id": 9007199254740993,
+			"issued": "2024-06-01",
+			"paid": 1296997036167,
+			"total": "19.99",
+			"lines": [{"sku": "a", "quantity": 2}],
+			"ref": "INV-1"
+		}`))
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+		record := native.(map[string]interface{})
+		gomega.Expect(record["id"]).To(gomega.Equal(int64(9007199254740993)))
+		gomega.Expect(record["issued"]).To(gomega.Equal(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)))
+		gomega.Expect(record["paid"]).To(gomega.Equal(map[string]interface{}{"long.timestamp-millis": time.UnixMilli(1296997036167).UTC()}))
+		gomega.Expect(record["total"].(*big.Rat).Cmp(big.NewRat(1999, 100))).To(gomega.Equal(0))
+		gomega.Expect(record["status"]).To(gomega.Equal("OPEN"))
+		gomega.Expect(record["lines"]).To(gomega.Equal([]interface{}{map[string]interface{}{"sku": "a", "qty": int32(2)}}))
+		gomega.Expect(record["note"]).To(gomega.BeNil())
+		gomega.Expect(record["ref"]).To(gomega.Equal(map[string]interface{}{"string": "INV-1"}))
+
+		codec, err := goavro.NewCodec(invoiceSchema)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		_, err = codec.BinaryFromNative(nil, native)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+	})
+
+	It("should read dates given as days and unions in Avro's JSON encoding", func() {
+		native, err := utils.AvroNativeFromJSON(parse(invoiceSchema), []byte(`{
+			"id": 1, "issued": 11613, "total": 5, "lines": [],
+			"note": {"string": "late"}, "ref": 42
+		}`))
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+		record := native.(map[string]interface{})
+		gomega.Expect(record["issued"]).To(gomega.Equal(time.Unix(11613*86400, 0).UTC()))
+		gomega.Expect(record["note"]).To(gomega.Equal(map[string]interface{}{"string": "late"}))
+		gomega.Expect(record["ref"]).To(gomega.Equal(map[string]interface{}{"long": int64(42)}))
+	})
+
+	DescribeTable("should name the path of invalid input",
+		func(document, message string) {
+			_, err := utils.AvroNativeFromJSON(parse(invoiceSchema), []byte(document))
+			gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring(message)))
+		},
+		Entry("missing field", `{"id": 1, "issued": 1, "total": 1}`, "lines: missing required field"),
+		Entry("nested type", `{"id": 1, "issued": 1, "total": 1, "lines": [{"sku": "a", "qty": "two"}]}`, "lines[0].qty: string cannot be written as int"),
+		Entry("enum symbol", `{"id": 1, "issued": 1, "total": 1, "lines": [], "status": "LOST"}`, "status: LOST is not a symbol of enum com.demo.Status"),
+		Entry("nullable branch", `{"id": 1, "issued": 1, "total": 1, "lines": [], "paid": "yesterday"}`, `paid: "yesterday" is not a date or RFC 3339 timestamp`),
+		Entry("union", `{"id": 1, "issued": 1, "total": 1, "lines": [], "ref": true}`, "ref: no branch of the union accepts boolean"),
+		Entry("integer", `{"id": 1.5, "issued": 1, "total": 1, "lines": []}`, "id: 1.5 is not an integer"),
+	)
+
+	It("should convert the demo test data", func() {
+		source, err := os.ReadFile(demoSchemaFile)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		native, err := utils.AvroNativeFromJSON(parse(string(source)), []byte(`{
+			"metadata": {"message_key": "tnKGDKUndl", "created_date": 1296997036167, "updated_date": "2011-02-06T13:37:16Z",
+				"outbox_published_date": 1296997036167, "event_type": "INSERT"},
+			"payload": {"id": "PKs-Is7j", "given_name": "Tom", "date_of_birth": 11613}
+		}`))
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+		codec := demoCodec(GinkgoT())
+		encoded, err := codec.BinaryFromNative(nil, native)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		decoded, err := utils.DecodeAvroWithCodec(codec, encoded)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		name, _ := utils.GetStringField(decoded, "payload.given_name")
+		gomega.Expect(name).To(gomega.Equal("Tom"))
+	})
+})
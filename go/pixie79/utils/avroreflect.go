@@ -0,0 +1,408 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	ratType      = reflect.TypeOf((*big.Rat)(nil))
+)
+
+// AvroNativeFromStruct converts a Go value, usually a tagged struct, into goavro native data for the
+// schema. Record fields are taken from the struct field whose avro tag names them, falling back to the
+// avro tag, json tag or field name that matches once case, underscores and dashes are ignored. Fields
+// the struct does not have take their schema default.
+//
+// Nil pointers become null, times become timestamps and dates, and union values are wrapped in the
+// branch AvroUnionBranch picks for them.
+func AvroNativeFromStruct(schema *AvroSchema, value interface{}) (interface{}, error) {
+	return nativeFromValue(schema, reflect.ValueOf(value), "")
+}
+
+// AvroNativeToStruct fills the value target points to from goavro native data decoded with the
+// schema, matching record fields to struct fields as AvroNativeFromStruct does.
+func AvroNativeToStruct(schema *AvroSchema, native interface{}, target interface{}) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("target must be a non-nil pointer, got %T", target)
+	}
+	return valueFromNative(schema, native, v.Elem(), "")
+}
+
+func nativeFromValue(schema *AvroSchema, v reflect.Value, path string) (interface{}, error) {
+	v = derefValue(v)
+
+	if schema.Type == "union" {
+		branch := unionBranchFor(schema, v)
+		switch {
+		case branch == nil && !v.IsValid():
+			return nil, fmt.Errorf("%s: nil value for a union without a null branch", displayPath(path))
+		case branch == nil:
+			return nil, fmt.Errorf("%s: no branch of the union accepts %s", displayPath(path), v.Type())
+		case branch.Type == "null":
+			return nil, nil
+		}
+		value, err := nativeFromValue(branch, v, path)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{branch.BranchName(): value}, nil
+	}
+
+	if !v.IsValid() {
+		if schema.Type == "null" {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%s: nil value for %s", displayPath(path), schema.BranchName())
+	}
+	if !acceptsValue(schema, v) {
+		return nil, fmt.Errorf("%s: %s cannot be written as %s", displayPath(path), v.Type(), schema.BranchName())
+	}
+
+	switch schema.Type {
+	case "null":
+		return nil, nil
+	case "boolean":
+		return v.Bool(), nil
+	case "int", "long":
+		if t, ok := timeOf(v); ok {
+			return t, nil
+		}
+		if v.Type() == durationType {
+			return time.Duration(v.Int()), nil
+		}
+		i := v.Int()
+		if schema.Type == "long" {
+			return i, nil
+		}
+		if i < math.MinInt32 || i > math.MaxInt32 {
+			return nil, fmt.Errorf("%s: %d overflows int", displayPath(path), i)
+		}
+		return int32(i), nil
+	case "float":
+		return float32(floatOf(v)), nil
+	case "double":
+		return floatOf(v), nil
+	case "string":
+		return v.String(), nil
+	case "enum":
+		symbol := v.String()
+		for _, known := range schema.Symbols {
+			if known == symbol {
+				return symbol, nil
+			}
+		}
+		return nil, fmt.Errorf("%s: %s is not a symbol of enum %s", displayPath(path), symbol, schema.Name)
+	case "bytes", "fixed":
+		if v.Type() == ratType {
+			return v.Interface(), nil
+		}
+		if v.Kind() == reflect.String {
+			return []byte(v.String()), nil
+		}
+		return v.Bytes(), nil
+	case "array":
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			item, err := nativeFromValue(schema.Items, v.Index(i), fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	case "map":
+		values := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			value, err := nativeFromValue(schema.Values, iter.Value(), joinPath(path, key))
+			if err != nil {
+				return nil, err
+			}
+			values[key] = value
+		}
+		return values, nil
+	case "record", "error":
+		if v.Kind() == reflect.Map {
+			return v.Interface(), nil
+		}
+		record := make(map[string]interface{}, len(schema.Fields))
+		for _, field := range schema.Fields {
+			fieldPath := joinPath(path, field.Name)
+			index, ok := structFieldFor(v.Type(), field)
+			if !ok {
+				if !field.HasDefault {
+					return nil, fmt.Errorf("%s: %s has no field for it and it has no default", fieldPath, v.Type())
+				}
+				value, err := AvroDefault(field.Type, field.Default)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", fieldPath, err)
+				}
+				record[field.Name] = value
+				continue
+			}
+			value, err := nativeFromValue(field.Type, v.FieldByIndex(index), fieldPath)
+			if err != nil {
+				return nil, err
+			}
+			record[field.Name] = value
+		}
+		return record, nil
+	}
+	return nil, fmt.Errorf("%s: unsupported Avro type %s", displayPath(path), schema.Type)
+}
+
+// acceptsValue reports whether a dereferenced Go value can be written with the schema, which picks
+// the union branch a value is written with.
+func acceptsValue(schema *AvroSchema, v reflect.Value) bool {
+	kind := v.Kind()
+	switch schema.Type {
+	case "null":
+		return false
+	case "boolean":
+		return kind == reflect.Bool
+	case "int", "long":
+		if _, ok := timeOf(v); ok {
+			return schema.Logical == "date" || strings.HasPrefix(schema.Logical, "timestamp-")
+		}
+		if v.Type() == durationType {
+			return strings.HasPrefix(schema.Logical, "time-")
+		}
+		return isInteger(kind)
+	case "float", "double":
+		return kind == reflect.Float32 || kind == reflect.Float64 || isInteger(kind)
+	case "string", "enum":
+		return kind == reflect.String
+	case "bytes", "fixed":
+		if v.Type() == ratType {
+			return schema.Logical == "decimal"
+		}
+		return isByteSlice(v.Type()) || (kind == reflect.String && schema.Type == "bytes")
+	case "array":
+		return (kind == reflect.Slice && !isByteSlice(v.Type())) || kind == reflect.Array
+	case "map":
+		return kind == reflect.Map && v.Type().Key().Kind() == reflect.String
+	case "record", "error":
+		if _, ok := timeOf(v); ok {
+			return false
+		}
+		return kind == reflect.Struct || (kind == reflect.Map && v.Type().Key().Kind() == reflect.String)
+	}
+	return false
+}
+
+func valueFromNative(schema *AvroSchema, native interface{}, v reflect.Value, path string) error {
+	if schema.Type == "union" {
+		if native == nil {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		branch, value, err := writerBranch(schema, native)
+		if err != nil {
+			return fmt.Errorf("%s: %w", displayPath(path), err)
+		}
+		return valueFromNative(branch, value, v, path)
+	}
+
+	switch {
+	case v.Kind() == reflect.Interface:
+		if native == nil {
+			v.Set(reflect.Zero(v.Type()))
+		} else {
+			v.Set(reflect.ValueOf(native))
+		}
+		return nil
+	case v.Kind() == reflect.Pointer && v.Type() != ratType:
+		if native == nil {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		elem := reflect.New(v.Type().Elem())
+		if err := valueFromNative(schema, native, elem.Elem(), path); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+
+	switch schema.Type {
+	case "null":
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	case "record", "error":
+		record, ok := native.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected a record, got %T", displayPath(path), native)
+		}
+		if v.Type() == reflect.TypeOf(record) {
+			v.Set(reflect.ValueOf(record))
+			return nil
+		}
+		if v.Kind() != reflect.Struct {
+			return fmt.Errorf("%s: cannot read a record into %s", displayPath(path), v.Type())
+		}
+		for _, field := range schema.Fields {
+			index, ok := structFieldFor(v.Type(), field)
+			if !ok {
+				continue
+			}
+			if err := valueFromNative(field.Type, record[field.Name], v.FieldByIndex(index), joinPath(path, field.Name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "array":
+		items, ok := native.([]interface{})
+		if !ok || v.Kind() != reflect.Slice {
+			return fmt.Errorf("%s: cannot read %T into %s", displayPath(path), native, v.Type())
+		}
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := valueFromNative(schema.Items, item, slice.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	case "map":
+		values, ok := native.(map[string]interface{})
+		if !ok || v.Kind() != reflect.Map {
+			return fmt.Errorf("%s: cannot read %T into %s", displayPath(path), native, v.Type())
+		}
+		m := reflect.MakeMapWithSize(v.Type(), len(values))
+		for key, value := range values {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := valueFromNative(schema.Values, value, elem, joinPath(path, key)); err != nil {
+				return err
+			}
+			m.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), elem)
+		}
+		v.Set(m)
+		return nil
+	}
+	return setPrimitive(schema, native, v, path)
+}
+
+// setPrimitive stores a goavro native primitive or logical value in v, converting times to the
+// integer form of the logical type when v is an integer.
+func setPrimitive(schema *AvroSchema, native interface{}, v reflect.Value, path string) error {
+	if t, ok := native.(time.Time); ok {
+		if timeField, isTime := timeFieldOf(v); isTime {
+			timeField.Set(reflect.ValueOf(t))
+			return nil
+		}
+		if isInteger(v.Kind()) {
+			switch schema.Logical {
+			case "date":
+				v.SetInt(t.Unix() / 86400)
+			case "timestamp-micros":
+				v.SetInt(t.UnixMicro())
+			default:
+				v.SetInt(t.UnixMilli())
+			}
+			return nil
+		}
+	}
+
+	nv := reflect.ValueOf(native)
+	switch {
+	case !nv.IsValid():
+		return fmt.Errorf("%s: null value for %s", displayPath(path), schema.BranchName())
+	case isInteger(v.Kind()) && isInteger(nv.Kind()):
+		v.SetInt(nv.Int())
+	case (v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64) && nv.CanFloat():
+		v.SetFloat(nv.Float())
+	case nv.Kind() == v.Kind() && nv.Type().ConvertibleTo(v.Type()):
+		v.Set(nv.Convert(v.Type()))
+	default:
+		return fmt.Errorf("%s: cannot read %T into %s", displayPath(path), native, v.Type())
+	}
+	return nil
+}
+
+// structFieldFor returns the index of the struct field holding the Avro field. An exact avro tag
+// match wins, then any avro tag, json tag or field name matching once normalised.
+func structFieldFor(t reflect.Type, field *AvroField) ([]int, bool) {
+	names := append([]string{field.Name}, field.Aliases...)
+	for _, name := range names {
+		for i := 0; i < t.NumField(); i++ {
+			if sf := t.Field(i); sf.IsExported() && tagName(sf, "avro") == name {
+				return sf.Index, true
+			}
+		}
+	}
+	for _, name := range names {
+		want := normaliseName(name)
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if !sf.IsExported() || tagName(sf, "avro") == "-" {
+				continue
+			}
+			for _, candidate := range []string{tagName(sf, "avro"), tagName(sf, "json"), sf.Name} {
+				if candidate != "" && candidate != "-" && normaliseName(candidate) == want {
+					return sf.Index, true
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+func tagName(sf reflect.StructField, key string) string {
+	name, _, _ := strings.Cut(sf.Tag.Get(key), ",")
+	return name
+}
+
+// normaliseName lower cases a name and drops underscores and dashes, so messageKey matches message_key.
+func normaliseName(name string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(name))
+}
+
+// timeOf returns the time held by a time.Time or by a struct embedding one, such as types.CustpTime.
+func timeOf(v reflect.Value) (time.Time, bool) {
+	if v.Type() == timeType {
+		return v.Interface().(time.Time), true
+	}
+	if v.Kind() == reflect.Struct && v.NumField() > 0 && v.Type().Field(0).Anonymous && v.Type().Field(0).Type == timeType {
+		return v.Field(0).Interface().(time.Time), true
+	}
+	return time.Time{}, false
+}
+
+// timeFieldOf returns the settable time.Time of a time.Time or of a struct embedding one.
+func timeFieldOf(v reflect.Value) (reflect.Value, bool) {
+	if v.Type() == timeType {
+		return v, true
+	}
+	if v.Kind() == reflect.Struct && v.NumField() > 0 && v.Type().Field(0).Anonymous && v.Type().Field(0).Type == timeType {
+		return v.Field(0), true
+	}
+	return reflect.Value{}, false
+}
+
+func isInteger(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func isByteSlice(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+}
+
+func floatOf(v reflect.Value) float64 {
+	if isInteger(v.Kind()) {
+		return float64(v.Int())
+	}
+	return v.Float()
+}
@@ -0,0 +1,132 @@
+package utils_test
+
+import (
+	"math/big"
+	"os"
+	"time"
+
+	pTypes "pixie79/types"
+	"pixie79/utils"
+
+	goavro "github.com/linkedin/goavro/v2"
+	. "github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+)
+
+var _ = Describe("Reflection-based Avro conversion", func() {
+	demoSchema := func() *utils.AvroSchema {
+		source, err := os.ReadFile(demoSchemaFile)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		schema, err := utils.ParseAvroSchema(string(source))
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		return schema
+	}
+
+	It("should encode a tagged struct the demo codec accepts", func() {
+		givenName := "Tom"
+		dateOfBirth := pTypes.CustpTime{Time: time.Unix(11613*86400, 0).UTC()}
+		created := time.UnixMilli(1296997036167).UTC()
+		event := pTypes.CustomerEvent{
+			Metadata: pTypes.EventMetadata{
+				MessageKey:  "tnKGDKUndl",
+				CreatedDate: pTypes.CustpTime{Time: created},
+				EventType:   "INSERT",
+			},
+			Payload: pTypes.Customer{Id: "PKs-Is7j", GivenName: &givenName, DateOfBirth: &dateOfBirth},
+		}
+
+		native, err := utils.AvroNativeFromStruct(demoSchema(), event)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+		codec := demoCodec(GinkgoT())
+		encoded, err := codec.BinaryFromNative(nil, native)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		decoded, err := utils.DecodeAvroWithCodec(codec, encoded)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+		name, _ := utils.GetStringField(decoded, "payload.given_name")
+		gomega.Expect(name).To(gomega.Equal("Tom"))
+		createdDate, _ := utils.GetField(decoded, "metadata.created_date")
+		gomega.Expect(createdDate).To(gomega.Equal(created))
+		gomega.Expect(decoded["payload"].(map[string]interface{})["title"]).To(gomega.BeNil())
+
+		var roundTrip pTypes.CustomerEvent
+		gomega.Expect(utils.AvroNativeToStruct(demoSchema(), decoded, &roundTrip)).To(gomega.Succeed())
+		gomega.Expect(roundTrip.Metadata.MessageKey).To(gomega.Equal("tnKGDKUndl"))
+		gomega.Expect(roundTrip.Payload.DateOfBirth.Time).To(gomega.Equal(dateOfBirth.Time))
+		gomega.Expect(roundTrip.Payload.LastName).To(gomega.BeNil())
+	})
+
+	It("should handle nested records, collections, enums and logical types", func() {
+		type line struct {
+			SKU  string           `avro:"sku"`
+			Tags map[string]int64 `json:"tags"`
+		}
+		type order struct {
+			ID      string         `avro:"id"`
+			Status  string         `avro:"status"`
+			Lines   []line         `avro:"lines"`
+			Parent  *order         `avro:"parent"`
+			Amount  *big.Rat       `avro:"amount"`
+			Timeout time.Duration  `avro:"timeout"`
+			Any     interface{}    `avro:"any"`
+			Extra   map[string]int `avro:"-"`
+		}
+		const orderSchema = `{"type": "record", "name": "Order", "namespace": "com.demo", "fields": [
+			{"name": "id", "type": "string"},
+			{"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["OPEN", "CLOSED"]}},
+			{"name": "lines", "type": {"type": "array", "items": {"type": "record", "name": "Line", "fields": [
+				{"name": "sku", "type": "string"},
+				{"name": "tags", "type": {"type": "map", "values": "long"}}
+			]}}},
+			{"name": "parent", "type": ["null", "Order"], "default": null},
+			{"name": "amount", "type": {"type": "bytes", "logicalType": "decimal", "precision": 9, "scale": 2}},
+			{"name": "timeout", "type": {"type": "int", "logicalType": "time-millis"}},
+			{"name": "any", "type": ["null", "int", "string"]},
+			{"name": "region", "type": "string", "default": "eu"}
+		]}`
+		schema, err := utils.ParseAvroSchema(orderSchema)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+		value := order{
+			ID: "o-1", Status: "OPEN", Amount: big.NewRat(5, 4), Timeout: 3 * time.Second, Any: "free text",
+			Lines:  []line{{SKU: "a", Tags: map[string]int64{"qty": 2}}},
+			Parent: &order{ID: "o-0", Status: "CLOSED", Lines: []line{}, Amount: big.NewRat(1, 2), Any: 7},
+		}
+		native, err := utils.AvroNativeFromStruct(schema, value)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+		codec, err := goavro.NewCodec(orderSchema)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		encoded, err := codec.BinaryFromNative(nil, native)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		decoded, _, err := codec.NativeFromBinary(encoded)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+		var roundTrip order
+		gomega.Expect(utils.AvroNativeToStruct(schema, decoded, &roundTrip)).To(gomega.Succeed())
+		gomega.Expect(roundTrip.Lines[0].Tags).To(gomega.Equal(map[string]int64{"qty": 2}))
+		gomega.Expect(roundTrip.Parent.ID).To(gomega.Equal("o-0"))
+		gomega.Expect(roundTrip.Parent.Any).To(gomega.Equal(int32(7)))
+		gomega.Expect(roundTrip.Amount.Cmp(big.NewRat(5, 4))).To(gomega.Equal(0))
+		gomega.Expect(roundTrip.Timeout).To(gomega.Equal(3 * time.Second))
+		gomega.Expect(roundTrip.Any).To(gomega.Equal("free text"))
+		gomega.Expect(decoded.(map[string]interface{})["region"]).To(gomega.Equal("eu"))
+	})
+
+	It("should report the path of a value the schema rejects", func() {
+		type payload struct {
+			ID int `avro:"id"`
+		}
+		type event struct {
+			Payload payload `avro:"payload"`
+		}
+		schema, err := utils.ParseAvroSchema(`{"type": "record", "name": "Event", "fields": [
+			{"name": "payload", "type": {"type": "record", "name": "Payload", "fields": [{"name": "id", "type": "string"}]}}
+		]}`)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+		_, err = utils.AvroNativeFromStruct(schema, event{})
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("payload.id: int cannot be written as string")))
+	})
+})
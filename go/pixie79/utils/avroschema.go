@@ -0,0 +1,365 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// AvroSchema is a parsed Avro schema. Named types are shared, so a recursive record refers back to itself.
+type AvroSchema struct {
+	Type    string
+	Name    string
+	Aliases []string
+	Logical string
+	Fields  []*AvroField
+	Symbols []string
+	// EnumDefault is the symbol used for writer symbols the reader does not know, when HasEnumDefault is set
+	EnumDefault    string
+	HasEnumDefault bool
+	Items          *AvroSchema
+	Values         *AvroSchema
+	Branches       []*AvroSchema
+	Size           int
+}
+
+// AvroField is a field of an Avro record. Default holds the JSON default value when HasDefault is set.
+type AvroField struct {
+	Name       string
+	Aliases    []string
+	Type       *AvroSchema
+	Default    interface{}
+	HasDefault bool
+}
+
+// goavroLogicalTypes are the logical types goavro names unions branches after, as type.logicalType.
+var goavroLogicalTypes = map[string]bool{
+	"long.timestamp-millis": true, "long.timestamp-micros": true, "int.time-millis": true,
+	"long.time-micros": true, "int.date": true, "bytes.decimal": true,
+}
+
+// ParseAvroSchema parses an Avro schema into its type model.
+func ParseAvroSchema(schema string) (*AvroSchema, error) {
+	decoder := json.NewDecoder(strings.NewReader(schema))
+	decoder.UseNumber()
+
+	var root interface{}
+	if err := decoder.Decode(&root); err != nil {
+		return nil, fmt.Errorf("unable to parse Avro schema: %w", err)
+	}
+	parser := &avroParser{named: map[string]*AvroSchema{}}
+	return parser.parse(root, "")
+}
+
+// BranchName is the key goavro uses for this type when it is a branch of a union.
+func (s *AvroSchema) BranchName() string {
+	switch s.Type {
+	case "record", "error", "enum", "fixed":
+		return s.Name
+	}
+	if s.Logical != "" && goavroLogicalTypes[s.Type+"."+s.Logical] {
+		return s.Type + "." + s.Logical
+	}
+	return s.Type
+}
+
+// Field returns the record field with the given name, or with an alias matching it.
+func (s *AvroSchema) Field(name string) *AvroField {
+	for _, field := range s.Fields {
+		if field.Name == name {
+			return field
+		}
+	}
+	for _, field := range s.Fields {
+		for _, alias := range field.Aliases {
+			if alias == name {
+				return field
+			}
+		}
+	}
+	return nil
+}
+
+// isNamed reports whether the type is a record, enum or fixed.
+func (s *AvroSchema) isNamed() bool {
+	switch s.Type {
+	case "record", "error", "enum", "fixed":
+		return true
+	}
+	return false
+}
+
+type avroParser struct {
+	named map[string]*AvroSchema
+}
+
+func (p *avroParser) parse(node interface{}, namespace string) (*AvroSchema, error) {
+	switch n := node.(type) {
+	case string:
+		return p.parseName(n, namespace)
+	case []interface{}:
+		union := &AvroSchema{Type: "union"}
+		for _, branch := range n {
+			parsed, err := p.parse(branch, namespace)
+			if err != nil {
+				return nil, err
+			}
+			union.Branches = append(union.Branches, parsed)
+		}
+		return union, nil
+	case map[string]interface{}:
+		return p.parseObject(n, namespace)
+	default:
+		return nil, fmt.Errorf("invalid Avro schema node %v", node)
+	}
+}
+
+func (p *avroParser) parseName(name, namespace string) (*AvroSchema, error) {
+	if avroPrimitiveTypes[name] {
+		return &AvroSchema{Type: name}, nil
+	}
+	if named, ok := p.named[qualifyAvroName(name, namespace)]; ok {
+		return named, nil
+	}
+	if named, ok := p.named[name]; ok {
+		return named, nil
+	}
+	return nil, fmt.Errorf("unknown Avro type %s", name)
+}
+
+func (p *avroParser) parseObject(object map[string]interface{}, namespace string) (*AvroSchema, error) {
+	typeName, ok := object["type"].(string)
+	if !ok {
+		return p.parse(object["type"], namespace)
+	}
+	logical, _ := object["logicalType"].(string)
+
+	switch typeName {
+	case "record", "error", "enum", "fixed":
+		return p.parseNamed(typeName, object, namespace)
+	case "array":
+		items, err := p.parse(object["items"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		return &AvroSchema{Type: "array", Items: items}, nil
+	case "map":
+		values, err := p.parse(object["values"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		return &AvroSchema{Type: "map", Values: values}, nil
+	default:
+		if avroPrimitiveTypes[typeName] {
+			return &AvroSchema{Type: typeName, Logical: logical}, nil
+		}
+		return p.parseName(typeName, namespace)
+	}
+}
+
+func (p *avroParser) parseNamed(typeName string, object map[string]interface{}, namespace string) (*AvroSchema, error) {
+	name, _ := object["name"].(string)
+	if name == "" {
+		return nil, fmt.Errorf("Avro %s without a name", typeName)
+	}
+	if ns, ok := object["namespace"].(string); ok {
+		namespace = ns
+	}
+	fullName := qualifyAvroName(name, namespace)
+	if idx := strings.LastIndex(fullName, "."); idx > 0 {
+		namespace = fullName[:idx]
+	} else {
+		namespace = ""
+	}
+
+	logical, _ := object["logicalType"].(string)
+	schema := &AvroSchema{
+		Type:    typeName,
+		Name:    fullName,
+		Aliases: qualifyAliases(object["aliases"], namespace),
+		Logical: logical,
+	}
+	p.named[fullName] = schema
+
+	switch typeName {
+	case "enum":
+		for _, symbol := range asSlice(object["symbols"]) {
+			symbolName, _ := symbol.(string)
+			schema.Symbols = append(schema.Symbols, symbolName)
+		}
+		schema.EnumDefault, schema.HasEnumDefault = object["default"].(string)
+	case "fixed":
+		size, ok := object["size"].(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("Avro fixed %s without a size", fullName)
+		}
+		parsed, err := size.Int64()
+		if err != nil {
+			return nil, fmt.Errorf("Avro fixed %s size: %w", fullName, err)
+		}
+		schema.Size = int(parsed)
+	default:
+		for _, field := range asSlice(object["fields"]) {
+			fieldObject, ok := field.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("invalid field in Avro record %s", fullName)
+			}
+			fieldType, err := p.parse(fieldObject["type"], namespace)
+			if err != nil {
+				return nil, err
+			}
+			fieldName, _ := fieldObject["name"].(string)
+			defaultValue, hasDefault := fieldObject["default"]
+			schema.Fields = append(schema.Fields, &AvroField{
+				Name:       fieldName,
+				Aliases:    qualifyAliases(fieldObject["aliases"], ""),
+				Type:       fieldType,
+				Default:    defaultValue,
+				HasDefault: hasDefault,
+			})
+		}
+	}
+	return schema, nil
+}
+
+func qualifyAliases(aliases interface{}, namespace string) []string {
+	var qualified []string
+	for _, alias := range asSlice(aliases) {
+		if name, ok := alias.(string); ok {
+			qualified = append(qualified, qualifyAvroName(name, namespace))
+		}
+	}
+	return qualified
+}
+
+func asSlice(value interface{}) []interface{} {
+	slice, _ := value.([]interface{})
+	return slice
+}
+
+// AvroDefault converts a JSON default value from the schema into the goavro native form for the type.
+// Union defaults apply to the first branch of the union.
+func AvroDefault(schema *AvroSchema, value interface{}) (interface{}, error) {
+	switch schema.Type {
+	case "null":
+		if value != nil {
+			return nil, fmt.Errorf("default %v is not null", value)
+		}
+		return nil, nil
+	case "boolean":
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("default %v is not a boolean", value)
+		}
+		return b, nil
+	case "int", "long", "float", "double":
+		return avroNumber(schema.Type, value)
+	case "string", "enum":
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("default %v is not a string", value)
+		}
+		return s, nil
+	case "bytes", "fixed":
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("default %v is not a string", value)
+		}
+		// Byte defaults are strings whose code points 0-255 are the bytes
+		var b bytes.Buffer
+		for _, r := range s {
+			b.WriteByte(byte(r))
+		}
+		return b.Bytes(), nil
+	case "array":
+		items := asSlice(value)
+		converted := make([]interface{}, len(items))
+		for i, item := range items {
+			c, err := AvroDefault(schema.Items, item)
+			if err != nil {
+				return nil, err
+			}
+			converted[i] = c
+		}
+		return converted, nil
+	case "map":
+		values, _ := value.(map[string]interface{})
+		converted := make(map[string]interface{}, len(values))
+		for k, v := range values {
+			c, err := AvroDefault(schema.Values, v)
+			if err != nil {
+				return nil, err
+			}
+			converted[k] = c
+		}
+		return converted, nil
+	case "record", "error":
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("default %v is not an object", value)
+		}
+		record := make(map[string]interface{}, len(schema.Fields))
+		for _, field := range schema.Fields {
+			fieldValue, ok := values[field.Name]
+			if !ok {
+				if !field.HasDefault {
+					return nil, fmt.Errorf("default for %s has no value for field %s", schema.Name, field.Name)
+				}
+				fieldValue = field.Default
+			}
+			c, err := AvroDefault(field.Type, fieldValue)
+			if err != nil {
+				return nil, err
+			}
+			record[field.Name] = c
+		}
+		return record, nil
+	case "union":
+		if len(schema.Branches) == 0 {
+			return nil, fmt.Errorf("default for an empty union")
+		}
+		branch := schema.Branches[0]
+		c, err := AvroDefault(branch, value)
+		if err != nil {
+			return nil, err
+		}
+		if branch.Type == "null" {
+			return nil, nil
+		}
+		return map[string]interface{}{branch.BranchName(): c}, nil
+	default:
+		return nil, fmt.Errorf("unsupported Avro type %s", schema.Type)
+	}
+}
+
+func avroNumber(typeName string, value interface{}) (interface{}, error) {
+	number, ok := value.(json.Number)
+	if !ok {
+		if f, isFloat := value.(float64); isFloat {
+			number = json.Number(fmt.Sprint(f))
+		} else {
+			return nil, fmt.Errorf("default %v is not a number", value)
+		}
+	}
+	switch typeName {
+	case "int", "long":
+		i, err := number.Int64()
+		if err != nil {
+			return nil, fmt.Errorf("default %v is not an integer", value)
+		}
+		if typeName == "int" {
+			return int32(i), nil
+		}
+		return i, nil
+	default:
+		f, err := number.Float64()
+		if err != nil {
+			return nil, err
+		}
+		if typeName == "float" {
+			return float32(f), nil
+		}
+		return f, nil
+	}
+}
@@ -0,0 +1,24 @@
+package utils
+
+import "strings"
+
+// Headers added to records written to the dead-letter topic.
+const (
+	DeadLetterHeaderPrefix         = "dlq."
+	DeadLetterStageHeader          = DeadLetterHeaderPrefix + "stage"
+	DeadLetterErrorHeader          = DeadLetterHeaderPrefix + "error"
+	DeadLetterSourceSchemaIDHeader = DeadLetterHeaderPrefix + "source_schema_id"
+	DeadLetterSourceTopicHeader    = DeadLetterHeaderPrefix + "source_topic"
+	DeadLetterTransformHeader      = DeadLetterHeaderPrefix + "transform"
+)
+
+// Stages at which a record can fail and be dead-lettered.
+const (
+	DeadLetterStageDecode = "decode"
+	DeadLetterStageEncode = "encode"
+)
+
+// IsDeadLetterHeader reports whether the header key was added when the record was dead-lettered.
+func IsDeadLetterHeader(key string) bool {
+	return strings.HasPrefix(key, DeadLetterHeaderPrefix)
+}
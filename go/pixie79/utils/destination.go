@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LatestSchemaVersion selects the most recent version registered under a subject.
+const LatestSchemaVersion = -1
+
+// DestinationSchema identifies the destination schema, either by its global ID or by subject and version.
+type DestinationSchema struct {
+	ID      int
+	Subject string
+	Version int
+}
+
+// SubjectLookup returns the global ID of the schema registered under subject and version.
+type SubjectLookup func(subject string, version int) (int, error)
+
+// ErrSchemaNotConfigured is returned by SchemaFromEnv when neither a subject nor a schema ID is set.
+var ErrSchemaNotConfigured = errors.New("schema not configured")
+
+// DestinationSchemaFromEnv reads the destination schema from DESTINATION_SUBJECT and DESTINATION_SCHEMA_VERSION,
+// which defaults to latest, or from DESTINATION_SCHEMA_ID. Exactly one of subject or ID must be set.
+func DestinationSchemaFromEnv() (DestinationSchema, error) {
+	destination, err := SchemaFromEnv("DESTINATION")
+	if errors.Is(err, ErrSchemaNotConfigured) {
+		return destination, fmt.Errorf("DESTINATION_SUBJECT or DESTINATION_SCHEMA_ID environment variable is required")
+	}
+	return destination, err
+}
+
+// SchemaFromEnv reads a schema from the <prefix>_SUBJECT and <prefix>_SCHEMA_VERSION environment variables,
+// or from <prefix>_SCHEMA_ID, returning ErrSchemaNotConfigured when neither is set.
+func SchemaFromEnv(prefix string) (DestinationSchema, error) {
+	subjectVar, versionVar, idVar := prefix+"_SUBJECT", prefix+"_SCHEMA_VERSION", prefix+"_SCHEMA_ID"
+	subject := os.Getenv(subjectVar)
+	schemaID := os.Getenv(idVar)
+
+	switch {
+	case subject != "" && schemaID != "":
+		return DestinationSchema{}, fmt.Errorf("set either %s or %s, not both", subjectVar, idVar)
+	case subject != "":
+		version, err := ParseSchemaVersion(os.Getenv(versionVar))
+		if err != nil {
+			return DestinationSchema{}, fmt.Errorf("%s: %w", versionVar, err)
+		}
+		return DestinationSchema{Subject: subject, Version: version}, nil
+	case schemaID != "":
+		id, err := strconv.Atoi(schemaID)
+		if err != nil {
+			return DestinationSchema{}, fmt.Errorf("%s not an integer: %s", idVar, schemaID)
+		}
+		return DestinationSchema{ID: id}, nil
+	default:
+		return DestinationSchema{}, ErrSchemaNotConfigured
+	}
+}
+
+// ParseSchemaVersion parses a subject version; an empty value or "latest" selects the latest version.
+func ParseSchemaVersion(version string) (int, error) {
+	if version == "" || strings.EqualFold(version, "latest") {
+		return LatestSchemaVersion, nil
+	}
+	parsed, err := strconv.Atoi(version)
+	if err != nil || parsed < 1 {
+		return 0, fmt.Errorf("schema version must be a positive integer or latest: %s", version)
+	}
+	return parsed, nil
+}
+
+// Resolve returns the global schema ID, looking the subject and version up when no ID was configured.
+func (d DestinationSchema) Resolve(lookup SubjectLookup) (int, error) {
+	if d.Subject == "" {
+		return d.ID, nil
+	}
+	id, err := lookup(d.Subject, d.Version)
+	if err != nil {
+		return 0, fmt.Errorf("destination schema %s not found, check the subject is registered: %w", d, err)
+	}
+	return id, nil
+}
+
+func (d DestinationSchema) String() string {
+	if d.Subject == "" {
+		return fmt.Sprintf("ID %d", d.ID)
+	}
+	if d.Version == LatestSchemaVersion {
+		return fmt.Sprintf("subject %s version latest", d.Subject)
+	}
+	return fmt.Sprintf("subject %s version %d", d.Subject, d.Version)
+}
@@ -0,0 +1,73 @@
+package utils_test
+
+import (
+	"errors"
+
+	"pixie79/utils"
+
+	. "github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+)
+
+var _ = Describe("Destination schema", func() {
+	BeforeEach(func() {
+		GinkgoT().Setenv("DESTINATION_SUBJECT", "")
+		GinkgoT().Setenv("DESTINATION_SCHEMA_VERSION", "")
+		GinkgoT().Setenv("DESTINATION_SCHEMA_ID", "")
+	})
+
+	lookup := func(subject string, version int) (int, error) {
+		if subject != "output-demo-value" {
+			return 0, errors.New("subject not found")
+		}
+		if version == utils.LatestSchemaVersion {
+			return 12, nil
+		}
+		return 10 + version, nil
+	}
+
+	It("should resolve the latest version of a subject by default", func() {
+		GinkgoT().Setenv("DESTINATION_SUBJECT", "output-demo-value")
+		destination, err := utils.DestinationSchemaFromEnv()
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(destination.Resolve(lookup)).To(gomega.Equal(12))
+	})
+
+	It("should resolve a pinned version of a subject", func() {
+		GinkgoT().Setenv("DESTINATION_SUBJECT", "output-demo-value")
+		GinkgoT().Setenv("DESTINATION_SCHEMA_VERSION", "1")
+		destination, err := utils.DestinationSchemaFromEnv()
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(destination.Resolve(lookup)).To(gomega.Equal(11))
+	})
+
+	It("should use a configured schema ID without a lookup", func() {
+		GinkgoT().Setenv("DESTINATION_SCHEMA_ID", "7")
+		destination, err := utils.DestinationSchemaFromEnv()
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(destination.Resolve(nil)).To(gomega.Equal(7))
+	})
+
+	It("should name the subject when it is not registered", func() {
+		GinkgoT().Setenv("DESTINATION_SUBJECT", "missing-value")
+		destination, err := utils.DestinationSchemaFromEnv()
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		_, err = destination.Resolve(lookup)
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("subject missing-value version latest")))
+	})
+
+	It("should reject invalid configurations", func() {
+		_, err := utils.DestinationSchemaFromEnv()
+		gomega.Expect(err).To(gomega.HaveOccurred())
+
+		GinkgoT().Setenv("DESTINATION_SUBJECT", "output-demo-value")
+		GinkgoT().Setenv("DESTINATION_SCHEMA_VERSION", "newest")
+		_, err = utils.DestinationSchemaFromEnv()
+		gomega.Expect(err).To(gomega.HaveOccurred())
+
+		GinkgoT().Setenv("DESTINATION_SCHEMA_VERSION", "")
+		GinkgoT().Setenv("DESTINATION_SCHEMA_ID", "7")
+		_, err = utils.DestinationSchemaFromEnv()
+		gomega.Expect(err).To(gomega.HaveOccurred())
+	})
+})
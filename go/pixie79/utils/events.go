@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Event actions select how a record is handled based on its metadata.event_type.
+const (
+	EventActionMask      = "mask"      // Apply the masking policy (default)
+	EventActionRedact    = "redact"    // Drop every field not in the keep list
+	EventActionTombstone = "tombstone" // Emit a null value with the same key
+)
+
+// EventTypeField is the record field holding the database operation (INSERT, UPDATE or DELETE).
+const EventTypeField = "metadata.event_type"
+
+// DefaultRedactKeepFields are the fields retained by the redact action when none are configured.
+var DefaultRedactKeepFields = []string{"metadata", "payload.id"}
+
+// UnmarshalEventActions parses a JSON map of event type to action, e.g. {"DELETE": "tombstone"}.
+// Event types are upper-cased so lookups are case-insensitive.
+func UnmarshalEventActions(data string) (map[string]string, error) {
+	var actions map[string]string
+	if err := json.Unmarshal([]byte(data), &actions); err != nil {
+		slog.Error("Error unmarshalling event actions", "Error", err)
+		return nil, err
+	}
+
+	normalised := make(map[string]string, len(actions))
+	for eventType, action := range actions {
+		action = strings.ToLower(action)
+		switch action {
+		case EventActionMask, EventActionRedact, EventActionTombstone:
+		default:
+			return nil, fmt.Errorf("unknown action %q for event type %s", action, eventType)
+		}
+		normalised[strings.ToUpper(eventType)] = action
+	}
+	return normalised, nil
+}
+
+// EventActionFor returns the configured action for the record's event type, defaulting to mask.
+func EventActionFor(record map[string]interface{}, actions map[string]string) string {
+	eventType, ok := GetStringField(record, EventTypeField)
+	if !ok {
+		return EventActionMask
+	}
+	if action, found := actions[strings.ToUpper(eventType)]; found {
+		return action
+	}
+	return EventActionMask
+}
+
+// RedactRecord removes, in place, every field of the record that is not in keepPaths.
+// A path keeps the whole sub-tree below it, e.g. "metadata" keeps all metadata fields while
+// "payload.id" keeps only the id of the payload. Removed fields are filled from the schema
+// defaults on encoding, so they must be nullable or have a default.
+func RedactRecord(record map[string]interface{}, keepPaths []string) {
+	keep := map[string]bool{}
+	nested := map[string][]string{}
+	for _, path := range keepPaths {
+		head, rest, found := strings.Cut(path, ".")
+		if !found {
+			keep[head] = true
+			continue
+		}
+		nested[head] = append(nested[head], rest)
+	}
+
+	for name, value := range record {
+		if keep[name] {
+			continue
+		}
+		if paths, ok := nested[name]; ok {
+			if child, ok := descendRecord(value); ok {
+				RedactRecord(child, paths)
+				continue
+			}
+		}
+		delete(record, name)
+	}
+}
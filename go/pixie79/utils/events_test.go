@@ -0,0 +1,53 @@
+package utils_test
+
+import (
+	"pixie79/utils"
+
+	. "github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+)
+
+var _ = Describe("Event type handling", func() {
+	var record map[string]interface{}
+
+	BeforeEach(func() {
+		record = map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"message_key": "tnKGDKUndl",
+				"event_type":  "DELETE",
+			},
+			"payload": map[string]interface{}{
+				"id":         "PKs-Is7j",
+				"given_name": map[string]interface{}{"string": "Tom"},
+				"last_name":  map[string]interface{}{"string": "Jones"},
+			},
+		}
+	})
+
+	It("should select the configured action for the event type", func() {
+		actions, err := utils.UnmarshalEventActions(`{"delete": "Tombstone", "UPDATE": "redact"}`)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(utils.EventActionFor(record, actions)).To(gomega.Equal(utils.EventActionTombstone))
+
+		utils.SetField(record, "metadata.event_type", "INSERT")
+		gomega.Expect(utils.EventActionFor(record, actions)).To(gomega.Equal(utils.EventActionMask))
+	})
+
+	It("should reject unknown actions", func() {
+		_, err := utils.UnmarshalEventActions(`{"DELETE": "forget"}`)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+	})
+
+	It("should keep only the id and metadata when redacting", func() {
+		utils.RedactRecord(record, utils.DefaultRedactKeepFields)
+		gomega.Expect(record).To(gomega.Equal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"message_key": "tnKGDKUndl",
+				"event_type":  "DELETE",
+			},
+			"payload": map[string]interface{}{
+				"id": "PKs-Is7j",
+			},
+		}))
+	})
+})
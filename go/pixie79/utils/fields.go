@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// avroPrimitiveBranches are the goavro union branch names that wrap a scalar value.
+var avroPrimitiveBranches = map[string]bool{
+	"null":    true,
+	"boolean": true,
+	"int":     true,
+	"long":    true,
+	"float":   true,
+	"double":  true,
+	"bytes":   true,
+	"string":  true,
+}
+
+// GetField walks a decoded record along a dotted path (e.g. "payload.country_of_residence")
+// and returns the value found. Union wrappers such as {"string": "UK"} are unwrapped so the
+// caller always receives the underlying value.
+func GetField(record map[string]interface{}, path string) (interface{}, bool) {
+	parent, name, ok := walkToParent(record, path)
+	if !ok {
+		return nil, false
+	}
+	value, ok := parent[name]
+	if !ok {
+		return nil, false
+	}
+	if _, inner, isUnion := unwrapUnion(value); isUnion {
+		return inner, true
+	}
+	return value, true
+}
+
+// GetStringField returns the string stored at the dotted path, or false if the field is
+// missing, null or not a string.
+func GetStringField(record map[string]interface{}, path string) (string, bool) {
+	value, ok := GetField(record, path)
+	if !ok {
+		return "", false
+	}
+	str, ok := value.(string)
+	return str, ok
+}
+
+// GetTimeField returns the time stored at the dotted path. Times are read as AvroNativeFromJSON reads a
+// timestamp-millis field: RFC 3339 strings, plain dates, or numbers of milliseconds since the epoch (days
+// below 1e10). Times already decoded from Avro are returned as they are.
+func GetTimeField(record map[string]interface{}, path string) (time.Time, bool) {
+	value, ok := GetField(record, path)
+	if !ok {
+		return time.Time{}, false
+	}
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case int32:
+		value = json.Number(strconv.FormatInt(int64(v), 10))
+	case int64:
+		value = json.Number(strconv.FormatInt(v, 10))
+	case float64:
+		value = json.Number(strconv.FormatFloat(v, 'f', -1, 64))
+	}
+	t, err := timeFromJSON(&AvroSchema{Type: "long", Logical: "timestamp-millis"}, value, path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t.(time.Time), true
+}
+
+// SetField replaces the value at the dotted path. If the existing value is a union wrapper
+// the new value is wrapped in the same branch, and a nil value is written as a null union.
+// It returns false if the parent of the field does not exist.
+func SetField(record map[string]interface{}, path string, value interface{}) bool {
+	parent, name, ok := walkToParent(record, path)
+	if !ok {
+		return false
+	}
+
+	if value == nil {
+		parent[name] = nil
+		return true
+	}
+
+	if branch, _, isUnion := unwrapUnion(parent[name]); isUnion && branch != "null" {
+		parent[name] = WrapUnionSimple(value, branch)
+		return true
+	}
+	parent[name] = value
+	return true
+}
+
+// walkToParent returns the map holding the last element of path along with that element's name.
+// Nested records wrapped in a union (e.g. {"com.demo.Customer": {...}}) are descended into.
+func walkToParent(record map[string]interface{}, path string) (map[string]interface{}, string, bool) {
+	if record == nil || path == "" {
+		return nil, "", false
+	}
+
+	parts := strings.Split(path, ".")
+	current := record
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := current[part]
+		if !ok {
+			return nil, "", false
+		}
+		nextMap, ok := descendRecord(next)
+		if !ok {
+			return nil, "", false
+		}
+		current = nextMap
+	}
+	return current, parts[len(parts)-1], true
+}
+
+// descendRecord returns the record map for value, unwrapping a single named-record union branch.
+func descendRecord(value interface{}) (map[string]interface{}, bool) {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	if len(m) == 1 {
+		for branch, inner := range m {
+			if innerMap, ok := inner.(map[string]interface{}); ok && strings.Contains(branch, ".") {
+				return innerMap, true
+			}
+		}
+	}
+	return m, true
+}
+
+// unwrapUnion reports whether value looks like a goavro union wrapper around a scalar,
+// returning the branch name and the wrapped value.
+func unwrapUnion(value interface{}) (string, interface{}, bool) {
+	m, ok := value.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return "", nil, false
+	}
+	for branch, inner := range m {
+		base := branch
+		if idx := strings.Index(branch, "."); idx > 0 {
+			base = branch[:idx]
+		}
+		if avroPrimitiveBranches[base] {
+			return branch, inner, true
+		}
+	}
+	return "", nil, false
+}
@@ -0,0 +1,17 @@
+package utils
+
+// Headers the loader adds to every record it produces.
+const (
+	ContentTypeHeader = "content-type"
+	SchemaIDHeader    = "schema-id"
+	EventTypeHeader   = "event-type"
+	SourceFileHeader  = "source-file"
+	LoadRunIDHeader   = "load-run-id"
+)
+
+// Content types of schema registry encoded values, as set in the content-type header.
+const (
+	ContentTypeAvro     = "application/vnd.apache.avro+binary"
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeJSON     = "application/json"
+)
@@ -1,8 +1,9 @@
 package utils
 
 import (
-	"encoding/base64"
 	"encoding/binary"
+	"errors"
+	"fmt"
 )
 
 // EncodeBuffer encodes the given schemaID into a byte array and returns the resulting header.
@@ -16,26 +17,19 @@ func EncodeBuffer(schemaID int) []byte {
 	return header
 }
 
-// b64DecodeMsg decodes a base64 encoded key and returns a subset of the key starting from the specified offset.
-//
-// Parameters:
-//   - b64Key: The base64 encoded key to be decoded.
-//   - offsetF: An optional integer representing the offset from which to start the subset of the key. If not provided, it defaults to 7.
+// ErrInvalidWireFormat is returned when a value does not carry the schema registry wire-format header.
+var ErrInvalidWireFormat = errors.New("value is not in schema registry wire format")
+
+// DecodeBuffer parses the schema registry wire-format header written by EncodeBuffer.
 //
-// Returns:
-//   - []byte: The subset of the key starting from the specified offset.
-//   - error: An error if the decoding or subset operation fails.
-func b64DecodeMsg(b64Key string, offsetF ...int) ([]byte, error) {
-	offset := 7
-	if len(offsetF) > 0 {
-		offset = offsetF[0]
+// The value must start with the magic byte 0 followed by a big-endian 4 byte schema ID.
+// The function returns the schema ID and the remaining payload, which shares the backing array of value.
+func DecodeBuffer(value []byte) (int, []byte, error) {
+	if len(value) < 5 {
+		return 0, nil, fmt.Errorf("%w: %d bytes is shorter than the 5 byte header", ErrInvalidWireFormat, len(value))
 	}
-
-	key, err := base64.StdEncoding.DecodeString(b64Key)
-	if err != nil {
-		return nil, err
+	if value[0] != 0 {
+		return 0, nil, fmt.Errorf("%w: unexpected magic byte %d", ErrInvalidWireFormat, value[0])
 	}
-
-	result := key[offset:]
-	return result, nil
+	return int(binary.BigEndian.Uint32(value[1:5])), value[5:], nil
 }
@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// jsonSchemaRootURL is the URL the registered schema is compiled under; references use their own names.
+const jsonSchemaRootURL = "schema-registry-root.json"
+
+// JSONSchema is a compiled schema registry JSON Schema.
+type JSONSchema struct {
+	schema *jsonschema.Schema
+}
+
+// CompileJSONSchema compiles a JSON Schema. References maps each $ref URL to the source of the schema
+// it points at, covering every schema imported directly or transitively.
+func CompileJSONSchema(schema string, references map[string]string) (*JSONSchema, error) {
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(jsonSchemaRootURL, strings.NewReader(schema)); err != nil {
+		slog.Error("Error loading JSON Schema", "Error", err)
+		return nil, err
+	}
+	for name, source := range references {
+		if err := compiler.AddResource(name, strings.NewReader(source)); err != nil {
+			slog.Error("Error loading referenced JSON Schema", "reference", name, "Error", err)
+			return nil, err
+		}
+	}
+
+	compiled, err := compiler.Compile(jsonSchemaRootURL)
+	if err != nil {
+		slog.Error("Error compiling JSON Schema", "Error", err)
+		return nil, err
+	}
+	return &JSONSchema{schema: compiled}, nil
+}
+
+// Validate checks a decoded JSON document against the schema.
+func (s *JSONSchema) Validate(document interface{}) error {
+	if err := s.schema.Validate(document); err != nil {
+		return fmt.Errorf("JSON document does not match schema: %w", err)
+	}
+	return nil
+}
+
+// DecodeJSON decodes a JSON object into a nested map. Numbers are kept as json.Number so integers
+// survive the round trip unchanged.
+func DecodeJSON(payload []byte) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+
+	var nestedMap map[string]interface{}
+	if err := decoder.Decode(&nestedMap); err != nil {
+		slog.Error("Error unmarshalling JSON", "Error", err)
+		return nil, err
+	}
+	if nestedMap == nil {
+		return nil, fmt.Errorf("JSON document is not an object")
+	}
+	return nestedMap, nil
+}
+
+// DecodeJSONSchema validates a JSON payload against the schema and returns it as a nested map.
+func DecodeJSONSchema(schema *JSONSchema, payload []byte) (map[string]interface{}, error) {
+	nestedMap, err := DecodeJSON(payload)
+	if err != nil {
+		return nil, err
+	}
+	if err := schema.Validate(nestedMap); err != nil {
+		return nil, err
+	}
+	return nestedMap, nil
+}
+
+// EncodeJSONSchema validates the nested map against the schema and returns it as a JSON payload.
+func EncodeJSONSchema(schema *JSONSchema, nestedMap map[string]interface{}) ([]byte, error) {
+	if err := schema.Validate(nestedMap); err != nil {
+		return nil, err
+	}
+	return json.Marshal(nestedMap)
+}
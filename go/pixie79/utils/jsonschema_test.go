@@ -0,0 +1,84 @@
+package utils_test
+
+import (
+	"encoding/json"
+
+	"pixie79/utils"
+
+	. "github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+)
+
+var _ = Describe("JSON Schema", func() {
+	const (
+		metadataSchema = `{
+			"type": "object",
+			"properties": {
+				"message_key": {"type": "string"},
+				"event_type": {"type": "string"}
+			},
+			"required": ["message_key"]
+		}`
+		customerSchema = `{
+			"type": "object",
+			"properties": {
+				"metadata": {"$ref": "metadata.json"},
+				"payload": {
+					"type": "object",
+					"properties": {
+						"id": {"type": "integer"},
+						"given_name": {"type": "string"},
+						"last_name": {"type": "string"},
+						"country_of_residence": {"type": "string"}
+					},
+					"required": ["id", "given_name"]
+				}
+			},
+			"required": ["metadata", "payload"]
+		}`
+	)
+
+	compile := func() *utils.JSONSchema {
+		schema, err := utils.CompileJSONSchema(customerSchema, map[string]string{"metadata.json": metadataSchema})
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		return schema
+	}
+
+	It("should decode valid documents and keep integers exact", func() {
+		decoded, err := utils.DecodeJSONSchema(compile(), []byte(`{
+			"metadata": {"message_key": "abc"},
+			"payload": {"id": 9007199254740993, "given_name": "Jane", "last_name": "Doe"}
+		}`))
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		id, ok := utils.GetField(decoded, "payload.id")
+		gomega.Expect(ok).To(gomega.BeTrue())
+		gomega.Expect(id).To(gomega.Equal(json.Number("9007199254740993")))
+	})
+
+	It("should reject documents that break a referenced schema", func() {
+		_, err := utils.DecodeJSONSchema(compile(), []byte(`{
+			"metadata": {"event_type": "demoEvent"},
+			"payload": {"id": 1, "given_name": "Jane"}
+		}`))
+		gomega.Expect(err).To(gomega.HaveOccurred())
+	})
+
+	It("should apply the masking policy to decoded documents", func() {
+		schema := compile()
+		decoded, err := utils.DecodeJSONSchema(schema, []byte(`{
+			"metadata": {"message_key": "abc"},
+			"payload": {"id": 1, "given_name": "Jane", "last_name": "Doe", "country_of_residence": "GB"}
+		}`))
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+		utils.DefaultMaskingPolicy().Apply(decoded)
+		encoded, err := utils.EncodeJSONSchema(schema, decoded)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(string(encoded)).NotTo(gomega.ContainSubstring("Jane"))
+	})
+
+	It("should refuse to encode documents that do not match the schema", func() {
+		_, err := utils.EncodeJSONSchema(compile(), map[string]interface{}{"payload": map[string]interface{}{}})
+		gomega.Expect(err).To(gomega.HaveOccurred())
+	})
+})
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// KeyFieldPrefix marks masking policy fields that refer to the record key rather than the value.
+const KeyFieldPrefix = "key."
+
+// KeySubject returns the schema registry subject for the keys of topic.
+func KeySubject(topic string) string {
+	return topic + "-key"
+}
+
+// AvroKeyFromRecord builds the JSON form of a record key from a decoded JSON event. A record key takes
+// each of its fields from the one event field with the same name, at any depth, and fails when the name
+// is used more than once. Any other key type takes the value at the dotted keyField path.
+func AvroKeyFromRecord(keySchema *AvroSchema, event map[string]interface{}, keyField string) (interface{}, error) {
+	if keySchema.Type != "record" {
+		if keyField == "" {
+			return nil, fmt.Errorf("a key field is required for %s keys", keySchema.Type)
+		}
+		value, ok := GetField(event, keyField)
+		if !ok {
+			return nil, fmt.Errorf("key field %s not found", keyField)
+		}
+		return value, nil
+	}
+
+	key := make(map[string]interface{}, len(keySchema.Fields))
+	for _, field := range keySchema.Fields {
+		paths, values := findField(event, field.Name, "")
+		switch len(paths) {
+		case 0:
+			if !field.HasDefault {
+				return nil, fmt.Errorf("key field %s not found", field.Name)
+			}
+			key[field.Name] = field.Default
+		case 1:
+			key[field.Name] = values[0]
+		default:
+			return nil, fmt.Errorf("key field %s is ambiguous, found at %s", field.Name, strings.Join(paths, ", "))
+		}
+	}
+	return key, nil
+}
+
+// RawKeyFromRecord returns the key for records without a key schema: the value at the dotted keyField
+// path of a decoded JSON event. Strings are used as they are and other values in their JSON form, so
+// every event of an entity gets the same key. A null value gives a nil key.
+func RawKeyFromRecord(event map[string]interface{}, keyField string) ([]byte, error) {
+	value, ok := GetField(event, keyField)
+	if !ok {
+		return nil, fmt.Errorf("key field %s not found", keyField)
+	}
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []byte(v), nil
+	}
+	return json.Marshal(value)
+}
+
+// findField returns the dotted path and value of every field called name in the record and its
+// nested objects, in name order.
+func findField(record map[string]interface{}, name, prefix string) ([]string, []interface{}) {
+	names := make([]string, 0, len(record))
+	for fieldName := range record {
+		names = append(names, fieldName)
+	}
+	sort.Strings(names)
+
+	var (
+		paths  []string
+		values []interface{}
+	)
+	for _, fieldName := range names {
+		if fieldName == name {
+			paths = append(paths, prefix+fieldName)
+			values = append(values, record[fieldName])
+		}
+		if nested, ok := record[fieldName].(map[string]interface{}); ok {
+			nestedPaths, nestedValues := findField(nested, name, prefix+fieldName+".")
+			paths = append(paths, nestedPaths...)
+			values = append(values, nestedValues...)
+		}
+	}
+	return paths, values
+}
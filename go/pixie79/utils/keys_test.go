@@ -0,0 +1,120 @@
+package utils_test
+
+import (
+	"encoding/json"
+	"time"
+
+	"pixie79/utils"
+
+	goavro "github.com/linkedin/goavro/v2"
+	. "github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+)
+
+var _ = Describe("Record keys", func() {
+	const keySchema = `{
+		"type": "record", "name": "CustomerKey", "namespace": "com.demo.event.v1",
+		"fields": [
+			{"name": "id", "type": "string"},
+			{"name": "message_key", "type": "string"},
+			{"name": "version", "type": "long", "default": 1}
+		]
+	}`
+
+	event := func() map[string]interface{} {
+		decoded, err := utils.DecodeJSON([]byte(`{
+			"metadata": {"message_key": "tnKGDKUndl"},
+			"payload": {"id": "PKs-Is7j", "given_name": "Tom", "country_of_residence": "UK"}
+		}`))
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		return decoded
+	}
+
+	It("should build a record key from the fields of the event", func() {
+		schema, err := utils.ParseAvroSchema(keySchema)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+		key, err := utils.AvroKeyFromRecord(schema, event(), "")
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+		keyJSON, err := json.Marshal(key)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		codec, err := goavro.NewCodec(keySchema)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		native, _, err := codec.NativeFromTextual(keyJSON)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(native).To(gomega.Equal(map[string]interface{}{
+			"id": "PKs-Is7j", "message_key": "tnKGDKUndl", "version": int64(1),
+		}))
+	})
+
+	It("should reject key fields whose name is used more than once in the event", func() {
+		schema, err := utils.ParseAvroSchema(keySchema)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+		decoded := event()
+		decoded["metadata"].(map[string]interface{})["id"] = "tnKGDKUndl"
+		_, err = utils.AvroKeyFromRecord(schema, decoded, "")
+		gomega.Expect(err).To(gomega.MatchError("key field id is ambiguous, found at metadata.id, payload.id"))
+	})
+
+	It("should take primitive keys from the key field", func() {
+		schema, err := utils.ParseAvroSchema(`"string"`)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+		key, err := utils.AvroKeyFromRecord(schema, event(), "payload.id")
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(key).To(gomega.Equal("PKs-Is7j"))
+
+		_, err = utils.AvroKeyFromRecord(schema, event(), "payload.missing")
+		gomega.Expect(err).To(gomega.HaveOccurred())
+	})
+
+	It("should take raw keys and record timestamps from event fields", func() {
+		decoded, err := utils.DecodeJSON([]byte(`{
+			"metadata": {"message_key": "tnKGDKUndl", "version": 3, "deleted": null,
+				"created_date": 1296997036167, "updated_date": "2011-02-06T13:37:16Z"}
+		}`))
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+		key, err := utils.RawKeyFromRecord(decoded, "metadata.message_key")
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(string(key)).To(gomega.Equal("tnKGDKUndl"))
+		key, err = utils.RawKeyFromRecord(decoded, "metadata.version")
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(string(key)).To(gomega.Equal("3"))
+		key, err = utils.RawKeyFromRecord(decoded, "metadata.deleted")
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(key).To(gomega.BeNil())
+		_, err = utils.RawKeyFromRecord(decoded, "payload.id")
+		gomega.Expect(err).To(gomega.MatchError("key field payload.id not found"))
+
+		updated, ok := utils.GetTimeField(decoded, "metadata.updated_date")
+		gomega.Expect(ok).To(gomega.BeTrue())
+		gomega.Expect(updated).To(gomega.Equal(time.Date(2011, 2, 6, 13, 37, 16, 0, time.UTC)))
+		created, ok := utils.GetTimeField(decoded, "metadata.created_date")
+		gomega.Expect(ok).To(gomega.BeTrue())
+		gomega.Expect(created).To(gomega.Equal(time.UnixMilli(1296997036167).UTC()))
+		_, ok = utils.GetTimeField(decoded, "metadata.message_key")
+		gomega.Expect(ok).To(gomega.BeFalse())
+	})
+
+	It("should apply key rules of the masking policy to the key only", func() {
+		policy, err := utils.UnmarshalMaskingPolicy(`{
+			"jurisdictions": {"UK": [
+				{"field": "payload.given_name", "action": "mask"},
+				{"field": "key.id", "action": "tokenise"}
+			]}
+		}`, "secret")
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+		record := event()
+		key := map[string]interface{}{"id": "PKs-Is7j"}
+		policy.Apply(record)
+		policy.ApplyToKey(record, key)
+
+		gomega.Expect(key["id"]).To(gomega.Equal(utils.TokeniseString("secret", "PKs-Is7j")))
+		id, _ := utils.GetStringField(record, "payload.id")
+		gomega.Expect(id).To(gomega.Equal("PKs-Is7j"))
+	})
+})
@@ -3,7 +3,6 @@ package utils
 import (
 	"fmt"
 	"log/slog"
-	"os"
 	"pixie79/utils"
 	"strconv"
 
@@ -13,23 +12,17 @@ import (
 
 func FetchAvroDestinationSchema(schemaURL string) (*avro.Codec, []byte, error) {
 	var (
-		destinationSchemaID    string
 		destinationSchemaIDInt int
 		destinationCodec       *avro.Codec
 		err                    error
 	)
 
-	destinationSchemaID = os.Getenv("DESTINATION_SCHEMA_ID")
-	if destinationSchemaID == "" {
-		panic("DESTINATION_SCHEMA_ID environment variable is required")
-	}
-
-	destinationSchemaIDInt, err = strconv.Atoi(destinationSchemaID)
+	destinationSchemaIDInt, err = newSchemaRegistryClient(schemaURL).destinationSchemaID()
 	if err != nil {
-		panic(fmt.Sprintf("DESTINATION_SCHEMA_ID not an integer: %s", destinationSchemaID))
+		panic(err.Error())
 	}
 
-	remoteSchema, err := getSchema(destinationSchemaID, schemaURL)
+	remoteSchema, err := getSchema(strconv.Itoa(destinationSchemaIDInt), schemaURL)
 	if err != nil {
 		panic(fmt.Sprintf("Error retrieving destination schema: %v\n", err))
 	}
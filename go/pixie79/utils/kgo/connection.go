@@ -0,0 +1,522 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/oauth"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+	"gopkg.in/yaml.v3"
+)
+
+// ConnectionConfig holds the addresses and security settings for Kafka, the schema registry and the
+// admin API. The YAML layout is that of an rpk profile, so a profile file can be used as it is.
+type ConnectionConfig struct {
+	Kafka          KafkaConfig    `yaml:"kafka_api"`
+	AdminAPI       AdminConfig    `yaml:"admin_api"`
+	SchemaRegistry RegistryConfig `yaml:"schema_registry"`
+}
+
+// KafkaConfig holds the seed brokers and the TLS and SASL settings of the Kafka API. TLS is off when
+// TLS is nil, and SASL is off when SASL is nil.
+type KafkaConfig struct {
+	Brokers []string    `yaml:"brokers,omitempty"`
+	TLS     *TLSConfig  `yaml:"tls,omitempty"`
+	SASL    *SASLConfig `yaml:"sasl,omitempty"`
+}
+
+// AdminConfig holds the addresses and TLS settings of the admin API.
+type AdminConfig struct {
+	Addresses []string   `yaml:"addresses,omitempty"`
+	TLS       *TLSConfig `yaml:"tls,omitempty"`
+}
+
+// RegistryConfig holds the addresses, TLS and authentication settings of the schema registry.
+type RegistryConfig struct {
+	Addresses   []string   `yaml:"addresses,omitempty"`
+	TLS         *TLSConfig `yaml:"tls,omitempty"`
+	BasicAuth   *BasicAuth `yaml:"basic_auth,omitempty"`
+	BearerToken string     `yaml:"bearer_token,omitempty"`
+}
+
+// TLSConfig configures TLS. Without a CA file the system roots are trusted, and a client certificate
+// and key enable mTLS.
+type TLSConfig struct {
+	CAFile             string `yaml:"ca_file,omitempty"`
+	CertFile           string `yaml:"cert_file,omitempty"`
+	KeyFile            string `yaml:"key_file,omitempty"`
+	ServerName         string `yaml:"server_name,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify,omitempty"`
+}
+
+// SASLConfig selects a SASL mechanism: PLAIN, SCRAM-SHA-256, SCRAM-SHA-512 or OAUTHBEARER. OAUTHBEARER
+// uses Token, the others User and Password.
+type SASLConfig struct {
+	Mechanism string `yaml:"mechanism"`
+	User      string `yaml:"user,omitempty"`
+	Password  string `yaml:"password,omitempty"`
+	Token     string `yaml:"token,omitempty"`
+}
+
+// BasicAuth holds HTTP basic auth credentials.
+type BasicAuth struct {
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+}
+
+// connectionSetting is a connection setting read from an environment variable and a flag.
+type connectionSetting struct {
+	env     string
+	flag    string
+	usage   string
+	boolean bool
+	set     func(cfg *ConnectionConfig, value string) error
+}
+
+func stringSetting(env, flagName, usage string, set func(cfg *ConnectionConfig, value string)) connectionSetting {
+	return connectionSetting{env: env, flag: flagName, usage: usage, set: func(cfg *ConnectionConfig, value string) error {
+		set(cfg, value)
+		return nil
+	}}
+}
+
+func boolSetting(env, flagName, usage string, set func(cfg *ConnectionConfig, value bool)) connectionSetting {
+	return connectionSetting{env: env, flag: flagName, usage: usage, boolean: true, set: func(cfg *ConnectionConfig, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		set(cfg, b)
+		return nil
+	}}
+}
+
+// connectionSettings are applied in order, so switching TLS off comes after the TLS settings that
+// would otherwise switch it back on.
+var connectionSettings = []connectionSetting{
+	stringSetting("REDPANDA_SEED_URL", "brokers", "Comma-separated Kafka seed brokers", func(cfg *ConnectionConfig, v string) { cfg.Kafka.Brokers = splitAddresses(v) }),
+	stringSetting("SCHEMA_REGISTRY_URL", "schema-registry", "Schema registry URL", func(cfg *ConnectionConfig, v string) { cfg.SchemaRegistry.Addresses = splitAddresses(v) }),
+	stringSetting("REDPANDA_ADMIN_URL", "admin-api", "Admin API URL", func(cfg *ConnectionConfig, v string) { cfg.AdminAPI.Addresses = splitAddresses(v) }),
+	stringSetting("REDPANDA_TLS_CA_FILE", "tls-ca", "CA certificate file for the Kafka API", func(cfg *ConnectionConfig, v string) { kafkaTLS(cfg).CAFile = v }),
+	stringSetting("REDPANDA_TLS_CERT_FILE", "tls-cert", "Client certificate file for Kafka mTLS", func(cfg *ConnectionConfig, v string) { kafkaTLS(cfg).CertFile = v }),
+	stringSetting("REDPANDA_TLS_KEY_FILE", "tls-key", "Client key file for Kafka mTLS", func(cfg *ConnectionConfig, v string) { kafkaTLS(cfg).KeyFile = v }),
+	stringSetting("REDPANDA_TLS_SERVER_NAME", "tls-server-name", "Server name to verify Kafka brokers against", func(cfg *ConnectionConfig, v string) { kafkaTLS(cfg).ServerName = v }),
+	boolSetting("REDPANDA_TLS_INSECURE_SKIP_VERIFY", "tls-insecure-skip-verify", "Skip verifying Kafka broker certificates", func(cfg *ConnectionConfig, b bool) { kafkaTLS(cfg).InsecureSkipVerify = b }),
+	boolSetting("REDPANDA_TLS_ENABLED", "tls", "Connect to Kafka over TLS", func(cfg *ConnectionConfig, b bool) { enableTLS(&cfg.Kafka.TLS, b) }),
+	stringSetting("REDPANDA_SASL_MECHANISM", "sasl-mechanism", "Kafka SASL mechanism: PLAIN, SCRAM-SHA-256, SCRAM-SHA-512 or OAUTHBEARER", func(cfg *ConnectionConfig, v string) { kafkaSASL(cfg).Mechanism = v }),
+	stringSetting("REDPANDA_SASL_USERNAME", "sasl-user", "Kafka SASL user", func(cfg *ConnectionConfig, v string) { kafkaSASL(cfg).User = v }),
+	stringSetting("REDPANDA_SASL_PASSWORD", "sasl-password", "Kafka SASL password", func(cfg *ConnectionConfig, v string) { kafkaSASL(cfg).Password = v }),
+	stringSetting("REDPANDA_SASL_TOKEN", "sasl-token", "Kafka OAUTHBEARER token", func(cfg *ConnectionConfig, v string) { kafkaSASL(cfg).Token = v }),
+	stringSetting("SCHEMA_REGISTRY_TLS_CA_FILE", "sr-tls-ca", "CA certificate file for the schema registry", func(cfg *ConnectionConfig, v string) { registryTLS(cfg).CAFile = v }),
+	stringSetting("SCHEMA_REGISTRY_TLS_CERT_FILE", "sr-tls-cert", "Client certificate file for schema registry mTLS", func(cfg *ConnectionConfig, v string) { registryTLS(cfg).CertFile = v }),
+	stringSetting("SCHEMA_REGISTRY_TLS_KEY_FILE", "sr-tls-key", "Client key file for schema registry mTLS", func(cfg *ConnectionConfig, v string) { registryTLS(cfg).KeyFile = v }),
+	stringSetting("SCHEMA_REGISTRY_TLS_SERVER_NAME", "sr-tls-server-name", "Server name to verify the schema registry against", func(cfg *ConnectionConfig, v string) { registryTLS(cfg).ServerName = v }),
+	boolSetting("SCHEMA_REGISTRY_TLS_INSECURE_SKIP_VERIFY", "sr-tls-insecure-skip-verify", "Skip verifying the schema registry certificate", func(cfg *ConnectionConfig, b bool) { registryTLS(cfg).InsecureSkipVerify = b }),
+	boolSetting("SCHEMA_REGISTRY_TLS_ENABLED", "sr-tls", "Use the schema registry TLS settings; https URLs use TLS with the system roots regardless", func(cfg *ConnectionConfig, b bool) { enableTLS(&cfg.SchemaRegistry.TLS, b) }),
+	stringSetting("SCHEMA_REGISTRY_USERNAME", "sr-user", "Schema registry basic auth user", func(cfg *ConnectionConfig, v string) { registryAuth(cfg).User = v }),
+	stringSetting("SCHEMA_REGISTRY_PASSWORD", "sr-password", "Schema registry basic auth password", func(cfg *ConnectionConfig, v string) { registryAuth(cfg).Password = v }),
+	stringSetting("SCHEMA_REGISTRY_BEARER_TOKEN", "sr-token", "Schema registry bearer token", func(cfg *ConnectionConfig, v string) { cfg.SchemaRegistry.BearerToken = v }),
+}
+
+func splitAddresses(v string) []string {
+	var addresses []string
+	for _, address := range strings.Split(v, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+func kafkaTLS(cfg *ConnectionConfig) *TLSConfig {
+	if cfg.Kafka.TLS == nil {
+		cfg.Kafka.TLS = &TLSConfig{}
+	}
+	return cfg.Kafka.TLS
+}
+
+func registryTLS(cfg *ConnectionConfig) *TLSConfig {
+	if cfg.SchemaRegistry.TLS == nil {
+		cfg.SchemaRegistry.TLS = &TLSConfig{}
+	}
+	return cfg.SchemaRegistry.TLS
+}
+
+func kafkaSASL(cfg *ConnectionConfig) *SASLConfig {
+	if cfg.Kafka.SASL == nil {
+		cfg.Kafka.SASL = &SASLConfig{}
+	}
+	return cfg.Kafka.SASL
+}
+
+func registryAuth(cfg *ConnectionConfig) *BasicAuth {
+	if cfg.SchemaRegistry.BasicAuth == nil {
+		cfg.SchemaRegistry.BasicAuth = &BasicAuth{}
+	}
+	return cfg.SchemaRegistry.BasicAuth
+}
+
+func enableTLS(tlsConfig **TLSConfig, enabled bool) {
+	switch {
+	case !enabled:
+		*tlsConfig = nil
+	case *tlsConfig == nil:
+		*tlsConfig = &TLSConfig{}
+	}
+}
+
+// LoadConnectionConfig reads a connection config file in the rpk profile layout.
+func LoadConnectionConfig(path string) (ConnectionConfig, error) {
+	var cfg ConnectionConfig
+	return cfg, cfg.applyFile(path)
+}
+
+// applyFile overrides the config with the settings in a file in the rpk profile layout.
+func (cfg *ConnectionConfig) applyFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read connection config: %w", err)
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("unable to parse connection config %s: %w", path, err)
+	}
+	return nil
+}
+
+// rpkConfig is the part of rpk.yaml that holds the connection profiles.
+type rpkConfig struct {
+	CurrentProfile string       `yaml:"current_profile"`
+	Profiles       []rpkProfile `yaml:"profiles"`
+}
+
+type rpkProfile struct {
+	Name             string `yaml:"name"`
+	ConnectionConfig `yaml:",inline"`
+}
+
+// DefaultRpkConfigPath returns where rpk keeps its profiles, rpk/rpk.yaml in the user config directory.
+func DefaultRpkConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "rpk", "rpk.yaml")
+}
+
+// LoadRpkProfile reads the named profile from an rpk.yaml file, or the current profile when name is
+// empty. It returns ok false when there is no rpk.yaml or no current profile to fall back to.
+func LoadRpkProfile(path string, name string) (cfg ConnectionConfig, ok bool, err error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && name == "" {
+		return cfg, false, nil
+	}
+	if err != nil {
+		return cfg, false, fmt.Errorf("unable to read rpk config: %w", err)
+	}
+
+	var rpk rpkConfig
+	if err := yaml.Unmarshal(data, &rpk); err != nil {
+		return cfg, false, fmt.Errorf("unable to parse rpk config %s: %w", path, err)
+	}
+	if name == "" {
+		name = rpk.CurrentProfile
+		if name == "" {
+			return cfg, false, nil
+		}
+	}
+	for _, profile := range rpk.Profiles {
+		if profile.Name == name {
+			return profile.ConnectionConfig, true, nil
+		}
+	}
+	return cfg, false, fmt.Errorf("rpk profile %s not found in %s", name, path)
+}
+
+// SchemaRegistryURL returns the URL of the first schema registry address, adding an http or https
+// scheme to bare host:port addresses as rpk profiles write them.
+func (cfg ConnectionConfig) SchemaRegistryURL() string {
+	return firstURL(cfg.SchemaRegistry.Addresses, cfg.SchemaRegistry.TLS != nil)
+}
+
+// AdminAPIURL returns the URL of the first admin API address, as SchemaRegistryURL does.
+func (cfg ConnectionConfig) AdminAPIURL() string {
+	return firstURL(cfg.AdminAPI.Addresses, cfg.AdminAPI.TLS != nil)
+}
+
+// SchemaRegistryConfig returns the schema registry settings. Without basic auth or a bearer token of
+// its own, the registry uses the Kafka SASL PLAIN or SCRAM user and password as basic auth, as rpk does.
+func (cfg ConnectionConfig) SchemaRegistryConfig() RegistryConfig {
+	registry := cfg.SchemaRegistry
+	if registry.BasicAuth == nil && registry.BearerToken == "" {
+		registry.BasicAuth = cfg.saslBasicAuth()
+	}
+	return registry
+}
+
+// AdminAPIBasicAuth returns the basic auth credentials of the admin API, the Kafka SASL PLAIN or SCRAM
+// user and password as rpk uses them, or nil without them.
+func (cfg ConnectionConfig) AdminAPIBasicAuth() *BasicAuth {
+	return cfg.saslBasicAuth()
+}
+
+// saslBasicAuth returns the Kafka SASL user and password as basic auth credentials, or nil when SASL
+// is off or uses a token.
+func (cfg ConnectionConfig) saslBasicAuth() *BasicAuth {
+	if cfg.Kafka.SASL == nil || cfg.Kafka.SASL.User == "" {
+		return nil
+	}
+	switch strings.ToUpper(strings.ReplaceAll(cfg.Kafka.SASL.Mechanism, "_", "-")) {
+	case "PLAIN", "SCRAM-SHA-256", "SCRAM-SHA-512":
+		return &BasicAuth{User: cfg.Kafka.SASL.User, Password: cfg.Kafka.SASL.Password}
+	}
+	return nil
+}
+
+func firstURL(addresses []string, secure bool) string {
+	if len(addresses) == 0 {
+		return ""
+	}
+	address := addresses[0]
+	if strings.Contains(address, "://") {
+		return address
+	}
+	if secure {
+		return "https://" + address
+	}
+	return "http://" + address
+}
+
+// ApplyEnv overrides the config with the REDPANDA_TLS_*, REDPANDA_SASL_* and SCHEMA_REGISTRY_*
+// environment variables that are set.
+func (cfg *ConnectionConfig) ApplyEnv() error {
+	for _, setting := range connectionSettings {
+		value, ok := os.LookupEnv(setting.env)
+		if !ok || value == "" {
+			continue
+		}
+		if err := setting.set(cfg, value); err != nil {
+			return fmt.Errorf("invalid %s: %w", setting.env, err)
+		}
+	}
+	return nil
+}
+
+// ConnectionFlags are the command line flags for the connection settings.
+type ConnectionFlags struct {
+	rpkConfig  *string
+	profile    *string
+	configFile *string
+	values     map[string]string
+}
+
+// RegisterConnectionFlags adds -rpk-config, -profile and -connection-config flags and a flag for
+// every connection setting to fs.
+func RegisterConnectionFlags(fs *flag.FlagSet) *ConnectionFlags {
+	flags := &ConnectionFlags{
+		rpkConfig:  fs.String("rpk-config", envOr("RPK_CONFIG", DefaultRpkConfigPath()), "rpk.yaml to read profiles from, defaults to RPK_CONFIG or the rpk config directory"),
+		profile:    fs.String("profile", os.Getenv("RPK_PROFILE"), "rpk profile to connect with, defaults to RPK_PROFILE or the current rpk profile"),
+		configFile: fs.String("connection-config", os.Getenv("REDPANDA_CONNECTION_CONFIG"), "YAML file with connection settings in the rpk profile layout, such as rpk-docker-profile.yml, defaults to REDPANDA_CONNECTION_CONFIG"),
+		values:     map[string]string{},
+	}
+	for _, setting := range connectionSettings {
+		name := setting.flag
+		usage := fmt.Sprintf("%s, overrides %s", setting.usage, setting.env)
+		record := func(v string) error {
+			flags.values[name] = v
+			return nil
+		}
+		if setting.boolean {
+			fs.BoolFunc(name, usage, record)
+		} else {
+			fs.Func(name, usage, record)
+		}
+	}
+	return flags
+}
+
+// Config builds the connection config from the rpk profile, then the config file, then the
+// environment, then the flags, each overriding the one before.
+func (f *ConnectionFlags) Config() (ConnectionConfig, error) {
+	var (
+		cfg ConnectionConfig
+		err error
+	)
+	if *f.rpkConfig != "" || *f.profile != "" {
+		if cfg, _, err = LoadRpkProfile(*f.rpkConfig, *f.profile); err != nil {
+			return cfg, err
+		}
+	}
+	if *f.configFile != "" {
+		if err := cfg.applyFile(*f.configFile); err != nil {
+			return cfg, err
+		}
+	}
+	if err := cfg.ApplyEnv(); err != nil {
+		return cfg, err
+	}
+	for _, setting := range connectionSettings {
+		value, ok := f.values[setting.flag]
+		if !ok {
+			continue
+		}
+		if err := setting.set(&cfg, value); err != nil {
+			return cfg, fmt.Errorf("invalid -%s: %w", setting.flag, err)
+		}
+	}
+	return cfg, nil
+}
+
+var (
+	connectionMu   sync.Mutex
+	connection     *ConnectionConfig
+	registryClient *http.Client
+)
+
+// SetConnectionConfig sets the connection config used by every Kafka and schema registry client the
+// package creates. Until it is called, the config is read from the environment.
+func SetConnectionConfig(cfg ConnectionConfig) {
+	connectionMu.Lock()
+	defer connectionMu.Unlock()
+	connection = &cfg
+	registryClient = nil
+}
+
+func currentConnection() (ConnectionConfig, error) {
+	connectionMu.Lock()
+	defer connectionMu.Unlock()
+	if connection == nil {
+		var cfg ConnectionConfig
+		if err := cfg.ApplyEnv(); err != nil {
+			return cfg, err
+		}
+		connection = &cfg
+	}
+	return *connection, nil
+}
+
+// kafkaSecurityOpts returns the kgo options for the TLS and SASL settings of the Kafka API.
+func kafkaSecurityOpts() ([]kgo.Opt, error) {
+	cfg, err := currentConnection()
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []kgo.Opt
+	if cfg.Kafka.TLS != nil {
+		tlsConfig, err := cfg.Kafka.TLS.Build()
+		if err != nil {
+			return nil, fmt.Errorf("invalid Kafka TLS settings: %w", err)
+		}
+		opts = append(opts, kgo.DialTLSConfig(tlsConfig))
+	}
+	if cfg.Kafka.SASL != nil {
+		mechanism, err := cfg.Kafka.SASL.Build()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, kgo.SASL(mechanism))
+	}
+	return opts, nil
+}
+
+// Build returns the crypto/tls config for the settings.
+func (c *TLSConfig) Build() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+	if c.CAFile != "" {
+		ca, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", c.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		if c.CertFile == "" || c.KeyFile == "" {
+			return nil, errors.New("mTLS needs both a client certificate and key file")
+		}
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// Build returns the franz-go SASL mechanism for the settings.
+func (c *SASLConfig) Build() (sasl.Mechanism, error) {
+	switch strings.ToUpper(strings.ReplaceAll(c.Mechanism, "_", "-")) {
+	case "PLAIN":
+		return plain.Auth{User: c.User, Pass: c.Password}.AsMechanism(), nil
+	case "SCRAM-SHA-256":
+		return scram.Auth{User: c.User, Pass: c.Password}.AsSha256Mechanism(), nil
+	case "SCRAM-SHA-512":
+		return scram.Auth{User: c.User, Pass: c.Password}.AsSha512Mechanism(), nil
+	case "OAUTHBEARER":
+		if c.Token == "" {
+			return nil, errors.New("SASL OAUTHBEARER needs a token")
+		}
+		return oauth.Auth{Token: c.Token}.AsMechanism(), nil
+	case "":
+		return nil, errors.New("SASL credentials are set without a mechanism")
+	}
+	return nil, fmt.Errorf("unsupported SASL mechanism %s", c.Mechanism)
+}
+
+// schemaRegistryHTTPClient returns the HTTP client for the schema registry TLS settings, shared by
+// every registry client until the connection config changes.
+func schemaRegistryHTTPClient(cfg RegistryConfig) (*http.Client, error) {
+	connectionMu.Lock()
+	defer connectionMu.Unlock()
+	if registryClient != nil {
+		return registryClient, nil
+	}
+	if cfg.TLS == nil {
+		registryClient = http.DefaultClient
+		return registryClient, nil
+	}
+	tlsConfig, err := cfg.TLS.Build()
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema registry TLS settings: %w", err)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	registryClient = &http.Client{Transport: transport}
+	return registryClient, nil
+}
+
+// authorize adds the schema registry credentials to a request.
+func (cfg RegistryConfig) authorize(req *http.Request) {
+	switch {
+	case cfg.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+cfg.BearerToken)
+	case cfg.BasicAuth != nil:
+		req.SetBasicAuth(cfg.BasicAuth.User, cfg.BasicAuth.Password)
+	}
+}
+
+func envOr(name string, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,245 @@
+package utils_test
+
+import (
+	"encoding/pem"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	pKgo "pixie79/utils/kgo"
+
+	. "github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+)
+
+var _ = Describe("Connection settings", func() {
+	It("should layer the config file, environment and flags", func() {
+		configFile := filepath.Join(GinkgoT().TempDir(), "connection.yaml")
+		gomega.Expect(os.WriteFile(configFile, []byte(`
+kafka_api:
+    tls:
+        ca_file: /etc/redpanda/ca.crt
+    sasl:
+        mechanism: SCRAM-SHA-256
+        user: loader
+        password: from-file
+schema_registry:
+    basic_auth:
+        user: registry
+        password: from-file
+`), 0o600)).To(gomega.Succeed())
+		GinkgoT().Setenv("RPK_CONFIG", filepath.Join(GinkgoT().TempDir(), "rpk.yaml"))
+		GinkgoT().Setenv("REDPANDA_SASL_PASSWORD", "from-env")
+		GinkgoT().Setenv("SCHEMA_REGISTRY_PASSWORD", "from-env")
+		GinkgoT().Setenv("SCHEMA_REGISTRY_TLS_ENABLED", "true")
+
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		flags := pKgo.RegisterConnectionFlags(fs)
+		gomega.Expect(fs.Parse([]string{"-connection-config", configFile, "-sr-password", "from-flag", "-tls-server-name", "broker.internal"})).To(gomega.Succeed())
+
+		cfg, err := flags.Config()
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(*cfg.Kafka.TLS).To(gomega.Equal(pKgo.TLSConfig{CAFile: "/etc/redpanda/ca.crt", ServerName: "broker.internal"}))
+		gomega.Expect(*cfg.Kafka.SASL).To(gomega.Equal(pKgo.SASLConfig{Mechanism: "SCRAM-SHA-256", User: "loader", Password: "from-env"}))
+		gomega.Expect(*cfg.SchemaRegistry.BasicAuth).To(gomega.Equal(pKgo.BasicAuth{User: "registry", Password: "from-flag"}))
+		gomega.Expect(cfg.SchemaRegistry.TLS).NotTo(gomega.BeNil())
+	})
+
+	It("should start from the current rpk profile", func() {
+		rpkConfig := filepath.Join(GinkgoT().TempDir(), "rpk.yaml")
+		gomega.Expect(os.WriteFile(rpkConfig, []byte(`
+version: 6
+current_profile: cloud
+profiles:
+    - name: demo
+      kafka_api:
+        brokers: [127.0.0.1:19092]
+    - name: cloud
+      kafka_api:
+        brokers: [seed-0.cloud:9092, seed-1.cloud:9092]
+        tls: {}
+        sasl:
+            mechanism: SCRAM-SHA-256
+            user: loader
+            password: from-profile
+      admin_api:
+        addresses: [seed-0.cloud:9644]
+        tls: {}
+      schema_registry:
+        addresses: [registry.cloud:30081]
+        tls: {}
+`), 0o600)).To(gomega.Succeed())
+		GinkgoT().Setenv("RPK_CONFIG", rpkConfig)
+		GinkgoT().Setenv("RPK_PROFILE", "")
+		GinkgoT().Setenv("REDPANDA_SASL_PASSWORD", "from-env")
+
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		flags := pKgo.RegisterConnectionFlags(fs)
+		gomega.Expect(fs.Parse([]string{"-brokers", "seed-2.cloud:9092, seed-3.cloud:9092"})).To(gomega.Succeed())
+
+		cfg, err := flags.Config()
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(cfg.Kafka.Brokers).To(gomega.Equal([]string{"seed-2.cloud:9092", "seed-3.cloud:9092"}))
+		gomega.Expect(cfg.Kafka.TLS).NotTo(gomega.BeNil())
+		gomega.Expect(*cfg.Kafka.SASL).To(gomega.Equal(pKgo.SASLConfig{Mechanism: "SCRAM-SHA-256", User: "loader", Password: "from-env"}))
+		gomega.Expect(cfg.SchemaRegistryURL()).To(gomega.Equal("https://registry.cloud:30081"))
+		gomega.Expect(cfg.AdminAPIURL()).To(gomega.Equal("https://seed-0.cloud:9644"))
+
+		demo, ok, err := pKgo.LoadRpkProfile(rpkConfig, "demo")
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(ok).To(gomega.BeTrue())
+		gomega.Expect(demo.Kafka.Brokers).To(gomega.Equal([]string{"127.0.0.1:19092"}))
+		gomega.Expect(demo.SchemaRegistryURL()).To(gomega.BeEmpty())
+
+		_, _, err = pKgo.LoadRpkProfile(rpkConfig, "staging")
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("rpk profile staging not found")))
+		_, ok, err = pKgo.LoadRpkProfile(filepath.Join(GinkgoT().TempDir(), "rpk.yaml"), "")
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(ok).To(gomega.BeFalse())
+	})
+
+	It("should authenticate to the schema registry with the SASL credentials of the rpk profile", func() {
+		registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if user, password, ok := r.BasicAuth(); !ok || user != "loader" || password != "from-profile" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			switch r.URL.Path {
+			case "/subjects/orders-key/versions/latest":
+				_, _ = w.Write([]byte(`{"subject": "orders-key", "version": 1, "id": 7, "schema": "\"string\""}`))
+			case "/schemas/ids/7":
+				_, _ = w.Write([]byte(`{"schema": "\"string\""}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer registry.Close()
+
+		rpkConfig := filepath.Join(GinkgoT().TempDir(), "rpk.yaml")
+		gomega.Expect(os.WriteFile(rpkConfig, []byte(`
+version: 6
+current_profile: cloud
+profiles:
+    - name: cloud
+      kafka_api:
+        brokers: [seed-0.cloud:9092]
+        sasl:
+            mechanism: SCRAM-SHA-512
+            user: loader
+            password: from-profile
+      admin_api:
+        addresses: [seed-0.cloud:9644]
+      schema_registry:
+        addresses: [`+registry.URL+`]
+`), 0o600)).To(gomega.Succeed())
+
+		cfg, ok, err := pKgo.LoadRpkProfile(rpkConfig, "")
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(ok).To(gomega.BeTrue())
+		gomega.Expect(cfg.SchemaRegistry.BasicAuth).To(gomega.BeNil())
+		gomega.Expect(*cfg.SchemaRegistryConfig().BasicAuth).To(gomega.Equal(pKgo.BasicAuth{User: "loader", Password: "from-profile"}))
+		gomega.Expect(*cfg.AdminAPIBasicAuth()).To(gomega.Equal(pKgo.BasicAuth{User: "loader", Password: "from-profile"}))
+
+		defer pKgo.SetConnectionConfig(pKgo.ConnectionConfig{})
+		pKgo.SetConnectionConfig(cfg)
+		codec, hdr, err := pKgo.FetchAvroKeySchema(cfg.SchemaRegistryURL(), "orders")
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(codec).NotTo(gomega.BeNil())
+		gomega.Expect(hdr).To(gomega.Equal([]byte{0, 0, 0, 0, 7}))
+
+		// registry credentials of its own take precedence, and tokens are not basic auth
+		cfg.SchemaRegistry.BearerToken = "registry-token"
+		gomega.Expect(cfg.SchemaRegistryConfig().BasicAuth).To(gomega.BeNil())
+		cfg.Kafka.SASL = &pKgo.SASLConfig{Mechanism: "OAUTHBEARER", Token: "t"}
+		gomega.Expect(cfg.AdminAPIBasicAuth()).To(gomega.BeNil())
+	})
+
+	It("should read the repository's rpk profile file as a connection config", func() {
+		cfg, err := pKgo.LoadConnectionConfig("../../../../rpk-docker-profile.yml")
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(cfg.Kafka.Brokers).To(gomega.Equal([]string{"127.0.0.1:19092"}))
+		gomega.Expect(cfg.SchemaRegistryURL()).To(gomega.Equal("http://127.0.0.1:18081"))
+		gomega.Expect(cfg.AdminAPIURL()).To(gomega.Equal("http://127.0.0.1:19644"))
+	})
+
+	It("should switch TLS off when it is disabled explicitly", func() {
+		GinkgoT().Setenv("REDPANDA_TLS_CA_FILE", "/etc/redpanda/ca.crt")
+		GinkgoT().Setenv("REDPANDA_TLS_ENABLED", "false")
+
+		var cfg pKgo.ConnectionConfig
+		gomega.Expect(cfg.ApplyEnv()).To(gomega.Succeed())
+		gomega.Expect(cfg.Kafka.TLS).To(gomega.BeNil())
+	})
+
+	DescribeTable("should build SASL mechanisms",
+		func(sasl pKgo.SASLConfig, name string) {
+			mechanism, err := sasl.Build()
+			gomega.Expect(err).NotTo(gomega.HaveOccurred())
+			gomega.Expect(mechanism.Name()).To(gomega.Equal(name))
+		},
+		Entry("plain", pKgo.SASLConfig{Mechanism: "plain", User: "u", Password: "p"}, "PLAIN"),
+		Entry("scram 256", pKgo.SASLConfig{Mechanism: "SCRAM-SHA-256", User: "u", Password: "p"}, "SCRAM-SHA-256"),
+		Entry("scram 512", pKgo.SASLConfig{Mechanism: "scram_sha_512", User: "u", Password: "p"}, "SCRAM-SHA-512"),
+		Entry("oauth", pKgo.SASLConfig{Mechanism: "OAUTHBEARER", Token: "t"}, "OAUTHBEARER"),
+	)
+
+	It("should reject unknown SASL mechanisms", func() {
+		_, err := (&pKgo.SASLConfig{Mechanism: "GSSAPI"}).Build()
+		gomega.Expect(err).To(gomega.MatchError("unsupported SASL mechanism GSSAPI"))
+	})
+
+	It("should call the schema registry over TLS with credentials", func() {
+		registry := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if user, password, ok := r.BasicAuth(); !ok || user != "registry" || password != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			switch r.URL.Path {
+			case "/subjects/orders-key/versions/latest":
+				_, _ = w.Write([]byte(`{"subject": "orders-key", "version": 1, "id": 7, "schema": "\"string\""}`))
+			case "/schemas/ids/7":
+				_, _ = w.Write([]byte(`{"schema": "\"string\""}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer registry.Close()
+
+		caFile := filepath.Join(GinkgoT().TempDir(), "ca.crt")
+		ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: registry.Certificate().Raw})
+		gomega.Expect(os.WriteFile(caFile, ca, 0o600)).To(gomega.Succeed())
+		defer pKgo.SetConnectionConfig(pKgo.ConnectionConfig{})
+
+		pKgo.SetConnectionConfig(pKgo.ConnectionConfig{SchemaRegistry: pKgo.RegistryConfig{
+			TLS:       &pKgo.TLSConfig{CAFile: caFile},
+			BasicAuth: &pKgo.BasicAuth{User: "registry", Password: "secret"},
+		}})
+		codec, hdr, err := pKgo.FetchAvroKeySchema(registry.URL, "orders")
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(codec).NotTo(gomega.BeNil())
+		gomega.Expect(hdr).To(gomega.Equal([]byte{0, 0, 0, 0, 7}))
+
+		// a topic without a key subject uses raw keys
+		codec, hdr, err = pKgo.FetchAvroKeySchema(registry.URL, "payments")
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(codec).To(gomega.BeNil())
+		gomega.Expect(hdr).To(gomega.BeNil())
+
+		// without the CA the registry certificate is not trusted
+		pKgo.SetConnectionConfig(pKgo.ConnectionConfig{SchemaRegistry: pKgo.RegistryConfig{
+			BasicAuth: &pKgo.BasicAuth{User: "registry", Password: "secret"},
+		}})
+		_, _, err = pKgo.FetchAvroKeySchema(registry.URL, "orders")
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("unable to look up key subject orders-key")))
+
+		// credentials the registry rejects are an error, not a missing key schema
+		pKgo.SetConnectionConfig(pKgo.ConnectionConfig{SchemaRegistry: pKgo.RegistryConfig{
+			TLS:       &pKgo.TLSConfig{CAFile: caFile},
+			BasicAuth: &pKgo.BasicAuth{User: "registry", Password: "wrong"},
+		}})
+		_, _, err = pKgo.FetchAvroKeySchema(registry.URL, "orders")
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("unexpected status code 401")))
+	})
+})
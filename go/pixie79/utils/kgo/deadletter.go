@@ -0,0 +1,298 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"pixie79/utils"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// defaultReplayPollTimeout bounds each poll of the dead-letter topic when ReplayOptions.PollTimeout is 0.
+const defaultReplayPollTimeout = 10 * time.Second
+
+// defaultReplayTransactionSize is the number of records replayed per transaction when
+// ReplayOptions.TransactionSize is 0.
+const defaultReplayTransactionSize = 1000
+
+// ReplayOptions selects which dead-lettered records are replayed and where to.
+type ReplayOptions struct {
+	// Topic overrides the dlq.source_topic header of each record
+	Topic string
+	// Stage only replays records that failed at this stage when set
+	Stage string
+	// DryRun reads and reports the records without producing them
+	DryRun bool
+	// Group records the replay progress when set: reading starts at the offsets committed for the
+	// group, and the offsets read up to are committed in the transaction of each batch
+	Group string
+	// PollTimeout is the longest wait for records before the replay fails, 0 waits 10 seconds
+	PollTimeout time.Duration
+	// TransactionSize is the most records replayed per transaction, 0 replays 1000
+	TransactionSize int
+}
+
+// ReplayStats counts the dead-lettered records read, replayed and skipped.
+type ReplayStats struct {
+	Read     int
+	Replayed int
+	Skipped  int
+}
+
+// ReplayDeadLetters reads the dead-letter topic up to its current end offsets and produces every
+// record, without its dead-letter headers, back to the topic it was originally written to.
+//
+// Records are produced in transactions of at most options.TransactionSize records, so only one batch
+// is held in memory. With options.Group set, the offsets read up to are committed for the group in
+// the transaction of each batch, so a replay that fails, or is run again, continues after the last
+// committed batch. Records skipped by options.Stage are committed too, so keep a group per stage.
+// Without a group every run replays the whole topic.
+func ReplayDeadLetters(ctx context.Context, seeds []string, dlqTopic string, options ReplayOptions) (ReplayStats, error) {
+	var stats ReplayStats
+
+	securityOpts, err := kafkaSecurityOpts()
+	if err != nil {
+		return stats, err
+	}
+	opts := append([]kgo.Opt{kgo.SeedBrokers(seeds...)}, securityOpts...)
+
+	adminClient, err := kgo.NewClient(opts...)
+	if err != nil {
+		return stats, fmt.Errorf("could not connect to Kafka: %w", err)
+	}
+	defer adminClient.Close()
+	admin := kadm.NewClient(adminClient)
+
+	starts, err := replayStartOffsets(ctx, admin, dlqTopic, options.Group)
+	if err != nil {
+		return stats, err
+	}
+	ends, err := admin.ListEndOffsets(ctx, dlqTopic)
+	if err == nil {
+		err = ends.Error()
+	}
+	if err != nil {
+		return stats, fmt.Errorf("unable to list end offsets of %s: %w", dlqTopic, err)
+	}
+
+	// remaining holds the end offset of every partition with records left to read, positions the
+	// offset after the last record read from each of them
+	remaining := map[int32]int64{}
+	positions := map[int32]int64{}
+	partitions := map[int32]kgo.Offset{}
+	ends.Each(func(end kadm.ListedOffset) {
+		start := starts[end.Partition]
+		if start >= end.Offset {
+			return
+		}
+		remaining[end.Partition] = end.Offset
+		positions[end.Partition] = start
+		partitions[end.Partition] = kgo.NewOffset().At(start)
+	})
+	if len(remaining) == 0 {
+		slog.Info("No dead-lettered records to replay", "topic", dlqTopic)
+		return stats, nil
+	}
+
+	client, err := kgo.NewClient(append(opts,
+		kgo.ConsumePartitions(map[string]map[int32]kgo.Offset{dlqTopic: partitions}),
+		// control records are kept so the position advances past transaction markers at the end
+		kgo.KeepControlRecords(),
+	)...)
+	if err != nil {
+		return stats, fmt.Errorf("could not connect to Kafka: %w", err)
+	}
+	defer client.Close()
+
+	// committed returns the positions as offsets of the group
+	committed := func() kadm.Offsets {
+		var offsets kadm.Offsets
+		for partition, position := range positions {
+			offsets.AddOffset(dlqTopic, partition, position, -1)
+		}
+		return offsets
+	}
+
+	var producer *Producer
+	if !options.DryRun {
+		producerOptions := ProducerOptions{Transactional: true}
+		if options.Group != "" {
+			producerOptions.TransactionOffsets = func() (string, kadm.Offsets) {
+				return options.Group, committed()
+			}
+		}
+		producer, err = NewProducer(seeds, producerOptions)
+		if err != nil {
+			return stats, err
+		}
+		defer producer.Close(context.Background())
+	}
+
+	transactionSize := options.TransactionSize
+	if transactionSize == 0 {
+		transactionSize = defaultReplayTransactionSize
+	}
+	pollTimeout := options.PollTimeout
+	if pollTimeout == 0 {
+		pollTimeout = defaultReplayPollTimeout
+	}
+
+	var (
+		batch []*kgo.Record
+		// advanced is set once records have been read since the last commit
+		advanced bool
+		// dryRun counts the records a dry run would have replayed
+		dryRun int
+	)
+	// replay produces the batch in one transaction, committing the positions with it, or commits the
+	// positions alone when every record read since the last batch was skipped
+	replay := func() error {
+		defer func() {
+			batch, advanced = nil, false
+		}()
+		switch {
+		case options.DryRun:
+			stats.Skipped += len(batch)
+			dryRun += len(batch)
+		case len(batch) > 0:
+			results, err := producer.Produce(ctx, batch)
+			stats.Replayed += results.Delivered()
+			if err != nil {
+				return fmt.Errorf("unable to replay dead-lettered records: %w", err)
+			}
+		case advanced && options.Group != "":
+			if err := admin.CommitAllOffsets(ctx, options.Group, committed()); err != nil {
+				return fmt.Errorf("unable to commit the offsets of group %s: %w", options.Group, err)
+			}
+		}
+		return nil
+	}
+
+	for len(remaining) > 0 {
+		pollCtx, cancel := context.WithTimeout(ctx, pollTimeout)
+		fetches := client.PollFetches(pollCtx)
+		cancel()
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		if err := fetches.Err0(); errors.Is(err, context.DeadlineExceeded) {
+			return stats, fmt.Errorf("no records read from %s for %s, %d partitions were not read to their end", dlqTopic, pollTimeout, len(remaining))
+		}
+		if err := fetches.Err(); err != nil {
+			return stats, fmt.Errorf("error reading dead-letter topic: %w", err)
+		}
+
+		for records := fetches.RecordIter(); !records.Done(); {
+			record := records.Next()
+			end, ok := remaining[record.Partition]
+			if !ok || record.Offset >= end {
+				continue
+			}
+			// the partition is done once its position reaches the listed end offset
+			positions[record.Partition] = record.Offset + 1
+			advanced = true
+			if record.Offset+1 >= end {
+				delete(remaining, record.Partition)
+			}
+			if record.Attrs.IsControl() {
+				continue
+			}
+
+			stats.Read++
+			replayed, err := ReplayRecord(record, options)
+			if err != nil {
+				stats.Skipped++
+				slog.Warn("Skipping dead-lettered record", "partition", record.Partition, "offset", record.Offset, "Error", err)
+				continue
+			}
+			batch = append(batch, replayed)
+			if len(batch) >= transactionSize {
+				if err := replay(); err != nil {
+					return stats, err
+				}
+			}
+		}
+	}
+	if err := replay(); err != nil {
+		return stats, err
+	}
+	if options.DryRun {
+		slog.Info("Dry run, not replaying records", "count", dryRun)
+	}
+	return stats, nil
+}
+
+// replayStartOffsets returns the offset each partition of the dead-letter topic is read from: the
+// offset committed for group, or the log start offset when that is later or nothing is committed.
+func replayStartOffsets(ctx context.Context, admin *kadm.Client, dlqTopic, group string) (map[int32]int64, error) {
+	listed, err := admin.ListStartOffsets(ctx, dlqTopic)
+	if err == nil {
+		err = listed.Error()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to list start offsets of %s: %w", dlqTopic, err)
+	}
+	starts := map[int32]int64{}
+	listed.Each(func(start kadm.ListedOffset) {
+		starts[start.Partition] = start.Offset
+	})
+	if group == "" {
+		return starts, nil
+	}
+
+	committed, err := admin.FetchOffsetsForTopics(ctx, group, dlqTopic)
+	if err == nil {
+		err = committed.Error()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch the offsets of group %s: %w", group, err)
+	}
+	for partition, start := range starts {
+		if offset, ok := committed.Lookup(dlqTopic, partition); ok && offset.At > start {
+			starts[partition] = offset.At
+		}
+	}
+	return starts, nil
+}
+
+// ReplayRecord returns a copy of the dead-lettered record addressed to its source topic, with the
+// dead-letter headers removed. Records that failed at a stage other than options.Stage are rejected.
+func ReplayRecord(record *kgo.Record, options ReplayOptions) (*kgo.Record, error) {
+	var (
+		stage   string
+		topic   = options.Topic
+		headers []kgo.RecordHeader
+	)
+
+	for _, header := range record.Headers {
+		switch header.Key {
+		case utils.DeadLetterStageHeader:
+			stage = string(header.Value)
+		case utils.DeadLetterSourceTopicHeader:
+			if topic == "" {
+				topic = string(header.Value)
+			}
+		}
+		if !utils.IsDeadLetterHeader(header.Key) {
+			headers = append(headers, header)
+		}
+	}
+
+	if options.Stage != "" && stage != options.Stage {
+		return nil, fmt.Errorf("record failed at stage %q, not %q", stage, options.Stage)
+	}
+	if topic == "" {
+		return nil, fmt.Errorf("record has no %s header and no topic was given", utils.DeadLetterSourceTopicHeader)
+	}
+
+	return &kgo.Record{
+		Key:     record.Key,
+		Value:   record.Value,
+		Headers: headers,
+		Topic:   topic,
+	}, nil
+}
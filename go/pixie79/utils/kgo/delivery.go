@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// DeliveryResult is the outcome of producing one record: where it was written, or why it was not.
+type DeliveryResult struct {
+	Record    *kgo.Record
+	Partition int32
+	Offset    int64
+	// Attempts counts how often the record was produced, 0 when it was never sent
+	Attempts int
+	Err      error
+}
+
+// ProduceResults holds a DeliveryResult for every record of a Produce or Stream call, in the order
+// the records were given.
+type ProduceResults []DeliveryResult
+
+// Delivered counts the records that were written.
+func (r ProduceResults) Delivered() int {
+	return len(r) - len(r.Failed())
+}
+
+// Failed returns the results of the records that were not written.
+func (r ProduceResults) Failed() ProduceResults {
+	var failed ProduceResults
+	for _, result := range r {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// Err summarises the failed records, or returns nil when every record was written.
+func (r ProduceResults) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d records failed: %w", len(failed), len(r), failed[0].Err)
+}
+
+// RetryPolicy controls how records that fail with a retriable error are produced again. Retriable
+// errors are Kafka errors marked retriable, and records that timed out or ran out of client retries.
+type RetryPolicy struct {
+	// Attempts is the most times a record is produced, 0 or 1 produce it once
+	Attempts int
+	// Backoff is the wait before the first retry, doubled before each further retry
+	Backoff time.Duration
+	// MaxBackoff caps the wait between retries, 0 leaves it uncapped
+	MaxBackoff time.Duration
+}
+
+// wait sleeps for the backoff before the retry following the given attempt.
+func (policy RetryPolicy) wait(ctx context.Context, attempt int) error {
+	backoff := policy.Backoff
+	for i := 1; i < attempt && (policy.MaxBackoff == 0 || backoff < policy.MaxBackoff); i++ {
+		backoff *= 2
+	}
+	if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+		backoff = policy.MaxBackoff
+	}
+
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isRetriable reports whether producing the record again may succeed.
+func isRetriable(err error) bool {
+	return kerr.IsRetriable(err) || errors.Is(err, kgo.ErrRecordTimeout) || errors.Is(err, kgo.ErrRecordRetries)
+}
+
+// FailedRecord is a record that could not be produced, as written to a failed records file.
+type FailedRecord struct {
+	Topic string `json:"topic"`
+	// Partition is kept for replays with the manual partitioner, other partitioners choose again
+	Partition int32          `json:"partition"`
+	Timestamp time.Time      `json:"timestamp"`
+	Key       []byte         `json:"key,omitempty"`
+	Value     []byte         `json:"value"`
+	Headers   []FailedHeader `json:"headers,omitempty"`
+	Error     string         `json:"error"`
+}
+
+// FailedHeader is a header of a FailedRecord.
+type FailedHeader struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// writeFailedRecords appends the failed results to the file, one JSON FailedRecord per line.
+func writeFailedRecords(path string, failed ProduceResults) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to open failed records file: %w", err)
+	}
+
+	encoder := json.NewEncoder(file)
+	for _, result := range failed {
+		record := FailedRecord{
+			Topic:     result.Record.Topic,
+			Partition: result.Record.Partition,
+			Timestamp: result.Record.Timestamp,
+			Key:       result.Record.Key,
+			Value:     result.Record.Value,
+			Error:     result.Err.Error(),
+		}
+		for _, header := range result.Record.Headers {
+			record.Headers = append(record.Headers, FailedHeader{Key: header.Key, Value: header.Value})
+		}
+		if err := encoder.Encode(record); err != nil {
+			file.Close()
+			return fmt.Errorf("unable to write failed records file: %w", err)
+		}
+	}
+	return file.Close()
+}
+
+// ReadFailedRecords reads a failed records file written by a Producer, returning records that can be
+// produced again.
+func ReadFailedRecords(path string) ([]*kgo.Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open failed records file: %w", err)
+	}
+	defer file.Close()
+
+	var records []*kgo.Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var failed FailedRecord
+		if err := json.Unmarshal(scanner.Bytes(), &failed); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		record := &kgo.Record{
+			Topic:     failed.Topic,
+			Partition: failed.Partition,
+			Timestamp: failed.Timestamp,
+			Key:       failed.Key,
+			Value:     failed.Value,
+		}
+		for _, header := range failed.Headers {
+			record.Headers = append(record.Headers, kgo.RecordHeader{Key: header.Key, Value: header.Value})
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read failed records file: %w", err)
+	}
+	return records, nil
+}
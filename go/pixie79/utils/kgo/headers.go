@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"pixie79/utils"
+
+	"github.com/redpanda-data/redpanda/src/transform-sdk/go/transform"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// KgoHeaders converts transform record headers to franz-go record headers.
+func KgoHeaders(headers []transform.RecordHeader) []kgo.RecordHeader {
+	if headers == nil {
+		return nil
+	}
+	kgoHeaders := make([]kgo.RecordHeader, len(headers))
+	for i, header := range headers {
+		kgoHeaders[i] = kgo.RecordHeader{Key: string(header.Key), Value: header.Value}
+	}
+	return kgoHeaders
+}
+
+// TransformHeaders converts franz-go record headers to transform record headers.
+func TransformHeaders(headers []kgo.RecordHeader) []transform.RecordHeader {
+	if headers == nil {
+		return nil
+	}
+	transformHeaders := make([]transform.RecordHeader, len(headers))
+	for i, header := range headers {
+		transformHeaders[i] = transform.RecordHeader{Key: []byte(header.Key), Value: header.Value}
+	}
+	return transformHeaders
+}
+
+// AddRecordHeaders appends the headers to those of every record.
+func AddRecordHeaders(records []*kgo.Record, headers ...kgo.RecordHeader) {
+	for _, record := range records {
+		record.Headers = append(record.Headers, headers...)
+	}
+}
+
+// ContentType returns the content-type header value for values of a schema type.
+func ContentType(schemaType string) string {
+	switch schemaType {
+	case SchemaTypeProtobuf:
+		return utils.ContentTypeProtobuf
+	case SchemaTypeJSON:
+		return utils.ContentTypeJSON
+	}
+	return utils.ContentTypeAvro
+}
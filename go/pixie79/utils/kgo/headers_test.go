@@ -0,0 +1,44 @@
+package utils_test
+
+import (
+	"pixie79/utils"
+	pKgo "pixie79/utils/kgo"
+
+	goavro "github.com/linkedin/goavro/v2"
+	. "github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+	"github.com/redpanda-data/redpanda/src/transform-sdk/go/transform"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+var _ = Describe("Record headers", func() {
+	It("should copy the headers given to the Avro converter onto the records", func() {
+		codec, err := goavro.NewCodec(`{"type": "record", "name": "Event", "fields": [{"name": "id", "type": "string"}]}`)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+		headers := []transform.RecordHeader{
+			{Key: []byte(utils.EventTypeHeader), Value: []byte("demoEvent")},
+			{Key: []byte("trace"), Value: nil},
+		}
+		records, err := pKgo.ConvertToAvroKgoRecords("", []byte(`[{"id": "a"}, {"id": "b"}]`), utils.EncodeBuffer(7), codec, []byte("key"), headers, "demo")
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(records).To(gomega.HaveLen(2))
+		for _, record := range records {
+			gomega.Expect(record.Headers).To(gomega.Equal([]kgo.RecordHeader{
+				{Key: utils.EventTypeHeader, Value: []byte("demoEvent")},
+				{Key: "trace"},
+			}))
+		}
+
+		pKgo.AddRecordHeaders(records, kgo.RecordHeader{Key: utils.SourceFileHeader, Value: []byte("demoEvent.json")})
+		gomega.Expect(records[0].Headers).To(gomega.HaveLen(3))
+		gomega.Expect(records[1].Headers[2].Key).To(gomega.Equal(utils.SourceFileHeader))
+		gomega.Expect(pKgo.TransformHeaders(records[0].Headers)[:2]).To(gomega.Equal(headers))
+	})
+
+	It("should name the content type of each schema type", func() {
+		gomega.Expect(pKgo.ContentType(pKgo.SchemaTypeAvro)).To(gomega.Equal(utils.ContentTypeAvro))
+		gomega.Expect(pKgo.ContentType(pKgo.SchemaTypeProtobuf)).To(gomega.Equal(utils.ContentTypeProtobuf))
+		gomega.Expect(pKgo.ContentType(pKgo.SchemaTypeJSON)).To(gomega.Equal(utils.ContentTypeJSON))
+	})
+})
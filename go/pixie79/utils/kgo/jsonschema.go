@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"pixie79/utils"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// FetchJSONDestinationSchema compiles the JSON Schema destination schema and returns it with its
+// wire-format header.
+func FetchJSONDestinationSchema(schemaURL string) (*utils.JSONSchema, []byte, error) {
+	registry := newSchemaRegistryClient(schemaURL)
+	schemaID, err := registry.destinationSchemaID()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	registered, err := registry.schemaByID(schemaID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to retrieve schema for ID %d: %w", schemaID, err)
+	}
+	references, err := utils.ResolveReferenceSources(registered.References, registry.resolveReference)
+	if err != nil {
+		return nil, nil, err
+	}
+	schema, err := utils.CompileJSONSchema(registered.Schema, references)
+	if err != nil {
+		return nil, nil, err
+	}
+	return schema, utils.EncodeBuffer(schemaID), nil
+}
+
+// ConvertToJSONSchemaKgoRecords validates each document of a JSON array against the schema and converts
+// it into a JSON Schema wire-format record.
+func ConvertToJSONSchemaKgoRecords(jsonData []byte, schema *utils.JSONSchema, hdr []byte, key []byte, topic string) ([]*kgo.Record, error) {
+	var (
+		documents []json.RawMessage
+		records   []*kgo.Record
+	)
+
+	if err := json.Unmarshal(jsonData, &documents); err != nil {
+		return nil, err
+	}
+
+	for i, document := range documents {
+		if _, err := utils.DecodeJSONSchema(schema, document); err != nil {
+			slog.Error("Invalid JSON record", "index", i, "Error", err)
+			return nil, fmt.Errorf("record %d: %w", i, err)
+		}
+
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, document); err != nil {
+			return nil, err
+		}
+		records = append(records, &kgo.Record{
+			Key:   key,
+			Value: append(append([]byte{}, hdr...), compact.Bytes()...),
+			Topic: topic,
+		})
+	}
+	return records, nil
+}
@@ -2,97 +2,413 @@ package utils
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"log/slog"
 	"pixie79/utils"
 
+	"github.com/twmb/franz-go/pkg/kadm"
 	"github.com/twmb/franz-go/pkg/kgo"
 )
 
-// createKafkaConnection creates a Kafka connection.
+// ErrProducerClosed is returned when records are produced after the producer has been closed.
+var ErrProducerClosed = errors.New("producer is closed")
+
+// Partitioners a Producer can use, see ProducerOptions.Partitioner.
+const (
+	// PartitionerMurmur2 hashes keys with murmur2 like Java clients, so an entity's records share a
+	// partition, and writes records without a key to one partition per batch
+	PartitionerMurmur2 = "murmur2"
+	// PartitionerSticky writes each batch to one partition, ignoring keys
+	PartitionerSticky = "sticky"
+	// PartitionerRoundRobin spreads records over every partition in turn, ignoring keys
+	PartitionerRoundRobin = "round-robin"
+	// PartitionerManual writes records to the partition set on the record
+	PartitionerManual = "manual"
+)
+
+// Partitioners lists the partitioner names accepted by ProducerOptions.
+var Partitioners = []string{PartitionerMurmur2, PartitionerSticky, PartitionerRoundRobin, PartitionerManual}
+
+// partitioner returns the franz-go partitioner of the given name, murmur2 when it is empty.
+func partitioner(name string) (kgo.Partitioner, error) {
+	switch name {
+	case "", PartitionerMurmur2:
+		return kgo.StickyKeyPartitioner(nil), nil
+	case PartitionerSticky:
+		return kgo.StickyPartitioner(), nil
+	case PartitionerRoundRobin:
+		return kgo.RoundRobinPartitioner(), nil
+	case PartitionerManual:
+		return kgo.ManualPartitioner(), nil
+	}
+	return nil, fmt.Errorf("unknown partitioner %s, expected one of %s", name, strings.Join(Partitioners, ", "))
+}
+
+// ProducerOptions configures how a Producer writes records.
+type ProducerOptions struct {
+	// Transactional commits records in transactions, otherwise they are written by the idempotent producer
+	Transactional bool
+	// TransactionalID identifies the producer's transactions, a random ID is used when empty
+	TransactionalID string
+	// TransactionSize is the most records committed, or flushed, together. 0 commits each call as one batch
+	TransactionSize int
+	// FlushInterval is the longest a streamed record waits for its batch to be committed or flushed,
+	// 0 waits for TransactionSize records or the end of the stream
+	FlushInterval time.Duration
+	// Partitioner picks the partition of each record, one of Partitioners, murmur2 when empty
+	Partitioner string
+	// Retry produces the batches of a transactional producer that failed with a retriable error again,
+	// it is ignored without transactions
+	Retry RetryPolicy
+	// TransactionOffsets, in transactional mode, is called before each batch is committed and returns
+	// consumer group offsets committed in the same transaction, so consumed records and the records
+	// produced from them are committed together. Nil, or empty offsets, commit none
+	TransactionOffsets func() (group string, offsets kadm.Offsets)
+	// FailedRecordsFile, when set, has every record that could not be produced appended to it, see ReadFailedRecords
+	FailedRecordsFile string
+	// Opts are extra franz-go client options, applied after the producer's own
+	Opts []kgo.Opt
+}
+
+// Producer writes records to Kafka with a single long-lived client, so it can be reused across
+// calls and files. Records are written in batches of at most TransactionSize records; in
+// transactional mode each batch is committed atomically, otherwise the batch is flushed by the
+// idempotent producer, whose own retries within RecordRetries do not duplicate records.
 //
-// ctx: the context.Context to use for the connection.
+// In transactional mode a batch that fails is retried in a new transaction as the Retry policy
+// allows, and records of a transaction that is finally rolled back all fail, while later batches
+// are still written. Without transactions failed records are not produced again: a record that
+// timed out may have been written already, so replaying it from the failed records file is
+// at-least-once delivery and can also put it after later records with the same key.
 //
-// Returns:
-// - *kgo.Client: the Kafka client.
-// - error: an error if the connection could not be established.
-func createKafkaConnection(ctx context.Context, seeds []string) (*kgo.Client, error) {
-	transactionID := utils.RandomString(20)
+// A Producer writes one batch at a time, concurrent calls wait for each other.
+type Producer struct {
+	client  *kgo.Client
+	options ProducerOptions
+
+	mu       sync.Mutex
+	closed   bool
+	batch    []*DeliveryResult
+	inflight sync.WaitGroup
+	results  ProduceResults
+	writeErr error
+}
+
+// NewProducer creates a Producer for the seed brokers, using the current connection settings.
+func NewProducer(seeds []string, options ProducerOptions) (*Producer, error) {
+	if options.TransactionSize < 0 {
+		return nil, fmt.Errorf("transaction size must not be negative, got %d", options.TransactionSize)
+	}
+	if options.TransactionalID != "" && !options.Transactional {
+		return nil, fmt.Errorf("transactional ID %s given for a producer without transactions", options.TransactionalID)
+	}
+
+	recordPartitioner, err := partitioner(options.Partitioner)
+	if err != nil {
+		return nil, err
+	}
 
 	opts := []kgo.Opt{
 		kgo.SeedBrokers(seeds...),
-		kgo.TransactionalID(transactionID),
-		kgo.RecordPartitioner(kgo.RoundRobinPartitioner()),
+		kgo.RecordPartitioner(recordPartitioner),
 		kgo.RecordRetries(4),
 		kgo.RequiredAcks(kgo.AllISRAcks()),
 		kgo.AllowAutoTopicCreation(),
 		kgo.ProducerBatchCompression(kgo.SnappyCompression()),
 	}
-	// Initialize public CAs for TLS
-	// opts = append(opts, kgo.DialTLSConfig(new(tls.Config)))
+	if options.Transactional {
+		if options.TransactionalID == "" {
+			options.TransactionalID = utils.RandomString(20)
+		}
+		opts = append(opts, kgo.TransactionalID(options.TransactionalID))
+	}
 
-	//// Initializes SASL/SCRAM 256
-	// Get Credentials from context
-	// opts = append(opts, kgo.SASL(scram.Auth{
-	// 	User: credentials.Username,
-	// 	Pass: credentials.Password,
-	// }.AsSha256Mechanism()))
+	securityOpts, err := kafkaSecurityOpts()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, securityOpts...)
+	opts = append(opts, options.Opts...)
 
 	client, err := kgo.NewClient(opts...)
 	if err != nil {
 		return nil, fmt.Errorf("could not connect to Kafka: %w", err)
 	}
 
-	return client, nil
+	return &Producer{client: client, options: options}, nil
 }
 
-// submitRecords submits Kafka records to a Kafka broker and commits them in a transaction.
-//
-// ctx - The context.Context object for cancellation signals and deadlines.
-// kafkaRecords - A slice of *kgo.Record objects representing the Kafka records to be submitted.
-// Returns an error if any step in the process fails.
-func SubmitRecords(ctx context.Context, kafkaRecords []*kgo.Record, seeds []string) error {
-	client, err := createKafkaConnection(context.Background(), seeds)
-	if err != nil {
-		return fmt.Errorf("failed to create Kafka client: %v", err)
+// Produce writes the records, committing or flushing every TransactionSize records and the
+// remainder before returning. The results hold the partition and offset, or the error, of every
+// record; records left unsent when the context is cancelled fail with its error. The returned
+// error summarises the failures.
+func (p *Producer) Produce(ctx context.Context, records []*kgo.Record) (ProduceResults, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, ErrProducerClosed
 	}
 
-	if err := client.BeginTransaction(); err != nil {
-		return fmt.Errorf("failed to begin transaction: %v", err)
+	p.results = make(ProduceResults, 0, len(records))
+	var (
+		err    error
+		unsent []*kgo.Record
+	)
+	for i, record := range records {
+		if err = ctx.Err(); err == nil {
+			err = p.add(ctx, record)
+		}
+		if err != nil {
+			unsent = records[i:]
+			break
+		}
 	}
+	p.endBatch(ctx)
 
-	if err := produceMessages(ctx, client, kafkaRecords); err != nil {
-		if rollbackErr := rollbackTransaction(client); rollbackErr != nil {
-			return rollbackErr
+	if len(unsent) > 0 {
+		failed := make([]DeliveryResult, len(unsent))
+		for i, record := range unsent {
+			failed[i] = DeliveryResult{Record: record, Err: err}
 		}
-		return fmt.Errorf("failed to produce messages: %v", err)
+		p.record(failed...)
 	}
+	return p.finish(err)
+}
 
-	if err := client.EndTransaction(ctx, kgo.TryCommit); err != nil {
-		return fmt.Errorf("failed to commit transaction: %v", err)
+// Stream writes records from the channel until it is closed, committing or flushing every
+// TransactionSize records and whenever FlushInterval passes with records waiting. Cancelling
+// the context rolls back the batch being written and returns the context's error. The results
+// are those of every record read from the channel, as for Produce.
+func (p *Producer) Stream(ctx context.Context, records <-chan *kgo.Record) (ProduceResults, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, ErrProducerClosed
 	}
 
-	slog.Info("produced Kafka records", "Count", len(kafkaRecords))
+	var flush <-chan time.Time
+	if p.options.FlushInterval > 0 {
+		ticker := time.NewTicker(p.options.FlushInterval)
+		defer ticker.Stop()
+		flush = ticker.C
+	}
+
+	p.results = nil
+	for {
+		select {
+		case record, ok := <-records:
+			if !ok {
+				p.endBatch(ctx)
+				return p.finish(nil)
+			}
+			if err := p.add(ctx, record); err != nil {
+				p.endBatch(ctx)
+				p.record(DeliveryResult{Record: record, Err: err})
+				return p.finish(err)
+			}
+		case <-flush:
+			p.endBatch(ctx)
+		case <-ctx.Done():
+			p.endBatch(ctx)
+			return p.finish(ctx.Err())
+		}
+	}
+}
+
+// Close waits for a running Produce or Stream to finish and closes the client. Streams should be
+// ended first, by closing their channel or cancelling their context. Closing twice is a no-op.
+func (p *Producer) Close(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+
+	err := p.client.Flush(ctx)
+	p.client.Close()
+	return err
+}
+
+// add produces a record into the current batch, starting a transaction if needed, and ends the batch
+// once it holds TransactionSize records.
+func (p *Producer) add(ctx context.Context, record *kgo.Record) error {
+	if len(p.batch) == 0 && p.options.Transactional {
+		if err := p.client.BeginTransaction(); err != nil {
+			return fmt.Errorf("failed to begin transaction: %v", err)
+		}
+	}
+
+	result := &DeliveryResult{Record: record}
+	p.batch = append(p.batch, result)
+	p.send(ctx, result)
+
+	if p.options.TransactionSize > 0 && len(p.batch) >= p.options.TransactionSize {
+		p.endBatch(ctx)
+	}
 	return nil
 }
 
-// produceMessages is a function that produces messages using a Kafka client.
-//
-// It takes the following parameters:
-// - ctx: the context.Context object used for cancellation and timeouts.
-// - client: a pointer to a kgo.Client object representing the Kafka client.
-// - records: a slice of pointers to kgo.Record objects representing the records to be produced.
-//
-// It returns an error if any error occurred while producing the messages.
-func produceMessages(ctx context.Context, client *kgo.Client, records []*kgo.Record) error {
-	var errPromise kgo.FirstErrPromise
+// send produces a record, storing where it was written or its error in the result.
+func (p *Producer) send(ctx context.Context, result *DeliveryResult) {
+	result.Attempts++
+	p.inflight.Add(1)
+	p.client.Produce(ctx, result.Record, func(record *kgo.Record, err error) {
+		result.Partition, result.Offset, result.Err = record.Partition, record.Offset, err
+		p.inflight.Done()
+	})
+}
 
-	for _, record := range records {
-		client.Produce(ctx, record, errPromise.Promise())
+// endBatch waits for every record of the current batch to be written and commits its transaction,
+// retrying as the policy allows, and records the batch's results.
+func (p *Producer) endBatch(ctx context.Context) {
+	batch := p.batch
+	p.batch = nil
+	if len(batch) == 0 {
+		return
 	}
 
-	return errPromise.Err()
+	for attempt := 1; ; attempt++ {
+		err := p.completeBatch(ctx, batch)
+		if err == nil {
+			break
+		}
+		if p.retry(ctx, batch, err, attempt) {
+			continue
+		}
+		if p.options.Transactional {
+			for _, result := range batch {
+				if result.Err == nil {
+					result.Err = fmt.Errorf("transaction rolled back: %w", err)
+				}
+			}
+		}
+		break
+	}
+
+	results := make(ProduceResults, len(batch))
+	for i, result := range batch {
+		results[i] = *result
+	}
+	slog.Info("produced Kafka records", "Count", results.Delivered())
+	if err := results.Err(); err != nil {
+		slog.Warn("failed to produce Kafka records", "Count", len(results.Failed()), "Error", err)
+	}
+	p.record(results...)
+}
+
+// completeBatch waits for the batch to be written and commits its transaction, returning the first
+// error. A transaction that fails is rolled back.
+func (p *Producer) completeBatch(ctx context.Context, batch []*DeliveryResult) error {
+	err := p.client.Flush(ctx)
+	if err != nil {
+		// fail the records still buffered, so every result is set
+		p.client.AbortBufferedRecords(context.Background())
+	}
+	p.inflight.Wait()
+	for _, result := range batch {
+		if err != nil {
+			break
+		}
+		err = result.Err
+	}
+
+	if !p.options.Transactional {
+		return err
+	}
+	if err == nil && p.options.TransactionOffsets != nil {
+		if group, offsets := p.options.TransactionOffsets(); len(offsets) > 0 {
+			err = commitTransactionOffsets(ctx, p.client, p.options.TransactionalID, group, offsets)
+		}
+	}
+	if err == nil {
+		if err = p.client.EndTransaction(ctx, kgo.TryCommit); err == nil {
+			return nil
+		}
+		err = fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	if rollbackErr := rollbackTransaction(p.client); rollbackErr != nil {
+		slog.Error("failed to roll back transaction", "Error", rollbackErr)
+	}
+	return err
+}
+
+// retry produces the whole batch again in a new transaction, after the policy's backoff, when another
+// attempt may succeed. Without transactions nothing is retried, see Producer.
+func (p *Producer) retry(ctx context.Context, batch []*DeliveryResult, err error, attempt int) bool {
+	if !p.options.Transactional || attempt >= p.options.Retry.Attempts || ctx.Err() != nil || !isRetriable(err) {
+		return false
+	}
+	for _, result := range batch {
+		if result.Err != nil && !isRetriable(result.Err) {
+			return false
+		}
+	}
+	if len(batch) == 0 {
+		return false
+	}
+
+	slog.Info("retrying Kafka records", "Count", len(batch), "Attempt", attempt+1, "Error", err)
+	if p.options.Retry.wait(ctx, attempt) != nil {
+		return false
+	}
+	if err := p.client.BeginTransaction(); err != nil {
+		slog.Error("failed to begin transaction", "Error", err)
+		return false
+	}
+	for _, result := range batch {
+		p.send(ctx, result)
+	}
+	return true
+}
+
+// record adds results to those of the current call and appends the failed ones to the failed
+// records file.
+func (p *Producer) record(results ...DeliveryResult) {
+	p.results = append(p.results, results...)
+
+	failed := ProduceResults(results).Failed()
+	if p.options.FailedRecordsFile == "" || len(failed) == 0 {
+		return
+	}
+	if err := writeFailedRecords(p.options.FailedRecordsFile, failed); err != nil && p.writeErr == nil {
+		slog.Error("failed to save failed records", "Error", err)
+		p.writeErr = err
+	}
+}
+
+// finish returns the results of the current call, with the error that stopped it joined to a summary
+// of its failures.
+func (p *Producer) finish(err error) (ProduceResults, error) {
+	results := p.results
+	p.results = nil
+	err = errors.Join(err, results.Err(), p.writeErr)
+	p.writeErr = nil
+	return results, err
+}
+
+// SubmitRecords submits Kafka records to a Kafka broker and commits them in a single transaction,
+// using a producer that is closed once the records are committed.
+//
+// ctx - The context.Context object for cancellation signals and deadlines.
+// kafkaRecords - A slice of *kgo.Record objects representing the Kafka records to be submitted.
+// Returns an error if any step in the process fails.
+func SubmitRecords(ctx context.Context, kafkaRecords []*kgo.Record, seeds []string) error {
+	producer, err := NewProducer(seeds, ProducerOptions{Transactional: true})
+	if err != nil {
+		return fmt.Errorf("failed to create Kafka client: %v", err)
+	}
+
+	_, err = producer.Produce(ctx, kafkaRecords)
+	if closeErr := producer.Close(ctx); err == nil {
+		err = closeErr
+	}
+	return err
 }
 
 // rollbackTransaction is a function that rolls back a transaction.
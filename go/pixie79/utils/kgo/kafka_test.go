@@ -0,0 +1,92 @@
+package utils_test
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	pKgo "pixie79/utils/kgo"
+
+	. "github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+var _ = Describe("Producer", func() {
+	// nothing listens on port 1, so records are never delivered
+	unreachable := []string{"127.0.0.1:1"}
+
+	DescribeTable("should reject invalid options",
+		func(options pKgo.ProducerOptions, message string) {
+			_, err := pKgo.NewProducer(unreachable, options)
+			gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring(message)))
+		},
+		Entry("negative size", pKgo.ProducerOptions{TransactionSize: -1}, "transaction size must not be negative"),
+		Entry("ID without transactions", pKgo.ProducerOptions{TransactionalID: "loader"}, "given for a producer without transactions"),
+		Entry("unknown partitioner", pKgo.ProducerOptions{Partitioner: "hash"}, "unknown partitioner hash, expected one of murmur2, sticky, round-robin, manual"),
+	)
+
+	It("should report every record that cannot be delivered and refuse records once closed", func() {
+		producer, err := pKgo.NewProducer(unreachable, pKgo.ProducerOptions{TransactionSize: 2})
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		results, err := producer.Produce(ctx, []*kgo.Record{{Topic: "demo", Value: []byte("a")}, {Topic: "demo", Value: []byte("b")}, {Topic: "demo", Value: []byte("c")}})
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("3 of 3 records failed")))
+		gomega.Expect(results).To(gomega.HaveLen(3))
+		gomega.Expect(results.Delivered()).To(gomega.Equal(0))
+		gomega.Expect(string(results[2].Record.Value)).To(gomega.Equal("c"))
+
+		gomega.Expect(producer.Close(context.Background())).To(gomega.Succeed())
+		gomega.Expect(producer.Close(context.Background())).To(gomega.Succeed())
+		_, err = producer.Produce(context.Background(), []*kgo.Record{{Topic: "demo"}})
+		gomega.Expect(err).To(gomega.MatchError(pKgo.ErrProducerClosed))
+	})
+
+	It("should not retry records without transactions and save those that fail for replay", func() {
+		failedFile := filepath.Join(GinkgoT().TempDir(), "failed.jsonl")
+		producer, err := pKgo.NewProducer(unreachable, pKgo.ProducerOptions{
+			Retry:             pKgo.RetryPolicy{Attempts: 2, Backoff: 10 * time.Millisecond},
+			FailedRecordsFile: failedFile,
+			Opts:              []kgo.Opt{kgo.RecordDeliveryTimeout(time.Second)},
+		})
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		defer producer.Close(context.Background())
+
+		record := &kgo.Record{Topic: "demo", Key: []byte("k"), Value: []byte("v"), Timestamp: time.UnixMilli(1296997036167), Headers: []kgo.RecordHeader{{Key: "source", Value: []byte("test")}}}
+		results, err := producer.Produce(context.Background(), []*kgo.Record{record})
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		// a record that timed out may have been written, so only a transaction can retry it safely
+		gomega.Expect(results[0].Attempts).To(gomega.Equal(1))
+		gomega.Expect(results[0].Err).To(gomega.MatchError(kgo.ErrRecordTimeout))
+
+		replay, err := pKgo.ReadFailedRecords(failedFile)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(replay).To(gomega.HaveLen(1))
+		gomega.Expect(replay[0].Topic).To(gomega.Equal("demo"))
+		gomega.Expect(replay[0].Key).To(gomega.Equal([]byte("k")))
+		gomega.Expect(replay[0].Value).To(gomega.Equal([]byte("v")))
+		gomega.Expect(replay[0].Headers).To(gomega.Equal(record.Headers))
+		gomega.Expect(replay[0].Partition).To(gomega.Equal(record.Partition))
+		gomega.Expect(replay[0].Timestamp).To(gomega.BeTemporally("==", record.Timestamp))
+	})
+
+	It("should end a stream when its context is cancelled", func() {
+		producer, err := pKgo.NewProducer(unreachable, pKgo.ProducerOptions{FlushInterval: time.Hour})
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		defer producer.Close(context.Background())
+
+		records := make(chan *kgo.Record, 1)
+		records <- &kgo.Record{Topic: "demo", Value: []byte("a")}
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			cancel()
+		}()
+		results, err := producer.Stream(ctx, records)
+		gomega.Expect(err).To(gomega.MatchError(context.Canceled))
+		gomega.Expect(results).To(gomega.HaveLen(1))
+		gomega.Expect(results.Failed()).To(gomega.HaveLen(1))
+	})
+})
@@ -0,0 +1,166 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"pixie79/utils"
+	"strconv"
+
+	avro "github.com/linkedin/goavro/v2"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// FetchAvroKeySchema resolves the Avro schema for record keys from DESTINATION_KEY_SUBJECT and
+// DESTINATION_KEY_SCHEMA_VERSION, or from DESTINATION_KEY_SCHEMA_ID, falling back to the key subject of
+// topic when it is registered. It returns a nil codec when keys are not schema encoded, and an error
+// when the registry fails for any reason other than the key subject not being registered.
+func FetchAvroKeySchema(schemaURL string, topic string) (*avro.Codec, []byte, error) {
+	registry := newSchemaRegistryClient(schemaURL)
+
+	var schemaID int
+	keySchema, err := utils.SchemaFromEnv("DESTINATION_KEY")
+	switch {
+	case errors.Is(err, utils.ErrSchemaNotConfigured):
+		registered, err := registry.schemaByVersion(utils.KeySubject(topic), utils.LatestSchemaVersion)
+		if errors.Is(err, errSchemaNotFound) {
+			slog.Info("No key schema registered, using raw keys", "subject", utils.KeySubject(topic))
+			return nil, nil, nil
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to look up key subject %s: %w", utils.KeySubject(topic), err)
+		}
+		schemaID = registered.ID
+	case err != nil:
+		return nil, nil, err
+	default:
+		schemaID, err = keySchema.Resolve(func(subject string, version int) (int, error) {
+			registered, err := registry.schemaByVersion(subject, version)
+			return registered.ID, err
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	schemaType, err := registry.schemaType(schemaID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if schemaType != SchemaTypeAvro {
+		return nil, nil, fmt.Errorf("key schema %d is a %s schema, only Avro keys are supported", schemaID, schemaType)
+	}
+
+	remoteSchema, err := getSchema(strconv.Itoa(schemaID), schemaURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to retrieve key schema: %w", err)
+	}
+	codec, err := avro.NewCodec(remoteSchema)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating Avro key codec: %w", err)
+	}
+	slog.Info("Encoding Avro keys", "schemaID", schemaID)
+	return codec, utils.EncodeBuffer(schemaID), nil
+}
+
+// EncodeAvroKeys sets the key of each record to an Avro key built from the event at the same position
+// in the JSON array. Record keys take their fields from the event fields of the same name; other keys
+// take the value at keyField.
+func EncodeAvroKeys(records []*kgo.Record, jsonData []byte, codec *avro.Codec, hdr []byte, keyField string) error {
+	var events []json.RawMessage
+	if err := json.Unmarshal(jsonData, &events); err != nil {
+		return err
+	}
+	if len(events) != len(records) {
+		return fmt.Errorf("%d events for %d records", len(events), len(records))
+	}
+
+	keySchema, err := utils.ParseAvroSchema(codec.Schema())
+	if err != nil {
+		return err
+	}
+
+	for i, event := range events {
+		eventMap, err := utils.DecodeJSON(event)
+		if err != nil {
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+		key, err := utils.AvroKeyFromRecord(keySchema, eventMap, keyField)
+		if err != nil {
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+		keyJSON, err := json.Marshal(key)
+		if err != nil {
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+		native, _, err := codec.NativeFromTextual(keyJSON)
+		if err != nil {
+			return fmt.Errorf("record %d: key does not match the key schema: %w", i, err)
+		}
+		records[i].Key, err = codec.BinaryFromNative(append([]byte{}, hdr...), native)
+		if err != nil {
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// RecordFields names the event fields, as dotted paths, that set the key, timestamp and partition of
+// the records produced from the events. An empty path leaves that part of the record as it is.
+type RecordFields struct {
+	// Key is used as the record key, for keys without a key schema
+	Key string
+	// Timestamp is used as the record timestamp, in place of the time it is produced
+	Timestamp string
+	// Partition is the partition written to with the manual partitioner
+	Partition string
+}
+
+// ApplyRecordFields sets the key, timestamp and partition of each record from the event at the same
+// position in the JSON array, as selected by fields.
+func ApplyRecordFields(records []*kgo.Record, jsonData []byte, fields RecordFields) error {
+	if fields == (RecordFields{}) {
+		return nil
+	}
+
+	var events []json.RawMessage
+	if err := json.Unmarshal(jsonData, &events); err != nil {
+		return err
+	}
+	if len(events) != len(records) {
+		return fmt.Errorf("%d events for %d records", len(events), len(records))
+	}
+
+	for i, event := range events {
+		eventMap, err := utils.DecodeJSON(event)
+		if err != nil {
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+
+		if fields.Key != "" {
+			records[i].Key, err = utils.RawKeyFromRecord(eventMap, fields.Key)
+			if err != nil {
+				return fmt.Errorf("record %d: %w", i, err)
+			}
+		}
+		if fields.Timestamp != "" {
+			timestamp, ok := utils.GetTimeField(eventMap, fields.Timestamp)
+			if !ok {
+				return fmt.Errorf("record %d: timestamp field %s is missing or not a time", i, fields.Timestamp)
+			}
+			records[i].Timestamp = timestamp
+		}
+		if fields.Partition != "" {
+			value, _ := utils.GetField(eventMap, fields.Partition)
+			number, ok := value.(json.Number)
+			partition, err := number.Int64()
+			if !ok || err != nil || partition < 0 || partition > math.MaxInt32 {
+				return fmt.Errorf("record %d: partition field %s is missing or not a partition number", i, fields.Partition)
+			}
+			records[i].Partition = int32(partition)
+		}
+	}
+	return nil
+}
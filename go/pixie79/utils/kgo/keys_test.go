@@ -0,0 +1,45 @@
+package utils_test
+
+import (
+	"time"
+
+	pKgo "pixie79/utils/kgo"
+
+	. "github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+var _ = Describe("Record fields", func() {
+	events := []byte(`[
+		{"metadata": {"message_key": "a", "updated_date": "2011-02-06T13:37:16Z", "shard": 2}, "payload": {"id": "PKs-Is7j"}},
+		{"metadata": {"message_key": "b", "updated_date": 1296997036167, "shard": 0}, "payload": {"id": 42}}
+	]`)
+
+	It("should set keys, timestamps and partitions from the events", func() {
+		records := []*kgo.Record{{Key: []byte("eventKey")}, {Key: []byte("eventKey")}}
+		gomega.Expect(pKgo.ApplyRecordFields(records, events, pKgo.RecordFields{
+			Key: "payload.id", Timestamp: "metadata.updated_date", Partition: "metadata.shard",
+		})).To(gomega.Succeed())
+
+		gomega.Expect(string(records[0].Key)).To(gomega.Equal("PKs-Is7j"))
+		gomega.Expect(string(records[1].Key)).To(gomega.Equal("42"))
+		gomega.Expect(records[0].Timestamp).To(gomega.Equal(time.Date(2011, 2, 6, 13, 37, 16, 0, time.UTC)))
+		gomega.Expect(records[1].Timestamp).To(gomega.Equal(time.UnixMilli(1296997036167).UTC()))
+		gomega.Expect(records[0].Partition).To(gomega.Equal(int32(2)))
+		gomega.Expect(records[1].Partition).To(gomega.Equal(int32(0)))
+	})
+
+	It("should leave records alone without fields and name the record that fails", func() {
+		records := []*kgo.Record{{Key: []byte("eventKey")}, {Key: []byte("eventKey")}}
+		gomega.Expect(pKgo.ApplyRecordFields(records, []byte(`not JSON`), pKgo.RecordFields{})).To(gomega.Succeed())
+		gomega.Expect(string(records[0].Key)).To(gomega.Equal("eventKey"))
+
+		err := pKgo.ApplyRecordFields(records, events, pKgo.RecordFields{Timestamp: "metadata.message_key"})
+		gomega.Expect(err).To(gomega.MatchError("record 0: timestamp field metadata.message_key is missing or not a time"))
+		err = pKgo.ApplyRecordFields(records, events, pKgo.RecordFields{Partition: "payload.id"})
+		gomega.Expect(err).To(gomega.MatchError("record 0: partition field payload.id is missing or not a partition number"))
+		err = pKgo.ApplyRecordFields(records[:1], events, pKgo.RecordFields{Key: "payload.id"})
+		gomega.Expect(err).To(gomega.MatchError("2 events for 1 records"))
+	})
+})
@@ -0,0 +1,13 @@
+package utils_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestKgo(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Kgo Suite")
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"pixie79/utils"
+
+	avro "github.com/linkedin/goavro/v2"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// ConvertOCFToAvroKgoRecords converts the records of an Avro object container file into schema registry
+// encoded records. Records are resolved from the writer schema in the file onto the destination schema.
+//
+// The records are also returned as an Avro JSON array, in destination schema form, for building keys.
+func ConvertOCFToAvroKgoRecords(ocf []byte, codec *avro.Codec, hdr []byte, key []byte, topic string) ([]*kgo.Record, []byte, error) {
+	writerSchema, natives, err := utils.ReadOCF(bytes.NewReader(ocf))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	writerCodec, err := avro.NewCodec(writerSchema)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error compiling OCF writer schema: %w", err)
+	}
+	var resolver *utils.AvroResolver
+	if writerCodec.CanonicalSchema() != codec.CanonicalSchema() {
+		resolver, err = utils.NewAvroResolver(writerSchema, codec.Schema())
+		if err != nil {
+			return nil, nil, err
+		}
+		slog.Info("Resolving OCF records onto the destination schema")
+	}
+
+	records := make([]*kgo.Record, 0, len(natives))
+	events := make([]json.RawMessage, 0, len(natives))
+	for i, native := range natives {
+		if resolver != nil {
+			native, err = resolver.Resolve(native)
+			if err != nil {
+				return nil, nil, fmt.Errorf("record %d: %w", i, err)
+			}
+		}
+		encoded, err := codec.BinaryFromNative(append([]byte{}, hdr...), native)
+		if err != nil {
+			slog.Error("Error encoding Avro", "Error", err)
+			return nil, nil, fmt.Errorf("record %d: %w", i, err)
+		}
+		textual, err := codec.TextualFromNative(nil, native)
+		if err != nil {
+			return nil, nil, fmt.Errorf("record %d: %w", i, err)
+		}
+		records = append(records, &kgo.Record{Key: key, Value: encoded, Topic: topic})
+		events = append(events, textual)
+	}
+
+	jsonData, err := json.Marshal(events)
+	if err != nil {
+		return nil, nil, err
+	}
+	return records, jsonData, nil
+}
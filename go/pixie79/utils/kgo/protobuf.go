@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"pixie79/utils"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Schema types as reported by the schema registry; an empty type means Avro.
+const (
+	SchemaTypeAvro     = "AVRO"
+	SchemaTypeProtobuf = "PROTOBUF"
+	SchemaTypeJSON     = "JSON"
+)
+
+// FetchDestinationSchemaType returns the type of the destination schema.
+func FetchDestinationSchemaType(schemaURL string) (string, error) {
+	registry := newSchemaRegistryClient(schemaURL)
+	schemaID, err := registry.destinationSchemaID()
+	if err != nil {
+		return "", err
+	}
+	return registry.schemaType(schemaID)
+}
+
+// FetchProtobufDestinationSchema compiles the Protobuf destination schema and returns the named message, or the first message when messageName is empty, with its wire-format header.
+func FetchProtobufDestinationSchema(schemaURL string, messageName string) (protoreflect.MessageDescriptor, []byte, error) {
+	registry := newSchemaRegistryClient(schemaURL)
+	schemaID, err := registry.destinationSchemaID()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	registered, err := registry.schemaByID(schemaID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to retrieve schema for ID %d: %w", schemaID, err)
+	}
+	references, err := utils.ResolveReferenceSources(registered.References, registry.resolveReference)
+	if err != nil {
+		return nil, nil, err
+	}
+	schema, err := utils.CompileProtobufSchema(registered.Schema, references)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	descriptor, indexes, err := schema.MessageByName(messageName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return descriptor, utils.EncodeProtobufBuffer(schemaID, indexes), nil
+}
+
+// ConvertToProtobufKgoRecords converts a JSON array of messages, keyed by .proto field names, into
+// Protobuf wire-format records.
+func ConvertToProtobufKgoRecords(jsonData []byte, descriptor protoreflect.MessageDescriptor, hdr []byte, key []byte, topic string) ([]*kgo.Record, error) {
+	var (
+		messages []json.RawMessage
+		records  []*kgo.Record
+	)
+
+	if err := json.Unmarshal(jsonData, &messages); err != nil {
+		return nil, err
+	}
+
+	for i, message := range messages {
+		encoded, err := utils.EncodeProtobufJSON(descriptor, message)
+		if err != nil {
+			slog.Error("Error encoding Protobuf record", "index", i, "Error", err)
+			return nil, fmt.Errorf("record %d: %w", i, err)
+		}
+		records = append(records, &kgo.Record{
+			Key:   key,
+			Value: append(append([]byte{}, hdr...), encoded...),
+			Topic: topic,
+		})
+	}
+	return records, nil
+}
@@ -1,14 +1,123 @@
 package utils
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"pixie79/utils"
 	"strconv"
-
-	sr "github.com/landoop/schema-registry"
 )
 
+// errSchemaNotFound is returned when the schema registry has no schema for the subject, version or ID.
+var errSchemaNotFound = errors.New("schema not found")
+
+// schemaRegistryClient is a minimal client for the schema registry REST API.
+type schemaRegistryClient struct {
+	baseURL string
+	client  *http.Client
+	config  RegistryConfig
+	// err is an invalid connection config, returned by every request
+	err error
+}
+
+// registeredSchema is the schema registry representation of a schema and its references.
+type registeredSchema struct {
+	Subject    string                  `json:"subject,omitempty"`
+	Version    int                     `json:"version,omitempty"`
+	ID         int                     `json:"id,omitempty"`
+	Schema     string                  `json:"schema"`
+	SchemaType string                  `json:"schemaType,omitempty"`
+	References []utils.SchemaReference `json:"references,omitempty"`
+}
+
+// newSchemaRegistryClient creates a client using the schema registry TLS and authentication settings
+// of the connection config, falling back to the Kafka SASL credentials for authentication.
+func newSchemaRegistryClient(registryURL string) *schemaRegistryClient {
+	registry := &schemaRegistryClient{baseURL: registryURL}
+	cfg, err := currentConnection()
+	if err != nil {
+		registry.err = err
+		return registry
+	}
+	registry.config = cfg.SchemaRegistryConfig()
+	registry.client, registry.err = schemaRegistryHTTPClient(cfg.SchemaRegistry)
+	return registry
+}
+
+// schemaByID fetches the schema registered under the global schema ID.
+func (cl *schemaRegistryClient) schemaByID(id int) (registeredSchema, error) {
+	var schema registeredSchema
+	err := cl.get(&schema, "/schemas/ids/", strconv.Itoa(id))
+	return schema, err
+}
+
+// schemaType returns the type of the schema registered under the global schema ID.
+func (cl *schemaRegistryClient) schemaType(id int) (string, error) {
+	schema, err := cl.schemaByID(id)
+	if err != nil {
+		return "", fmt.Errorf("unable to retrieve schema for ID %d: %w", id, err)
+	}
+	if schema.SchemaType == "" {
+		return SchemaTypeAvro, nil
+	}
+	return schema.SchemaType, nil
+}
+
+// schemaByVersion fetches the schema registered under subject and version; version -1 is the latest.
+func (cl *schemaRegistryClient) schemaByVersion(subject string, version int) (registeredSchema, error) {
+	var schema registeredSchema
+	versionPath := strconv.Itoa(version)
+	if version < 0 {
+		versionPath = "latest"
+	}
+	err := cl.get(&schema, "/subjects/", url.PathEscape(subject), "/versions/", versionPath)
+	return schema, err
+}
+
+func (cl *schemaRegistryClient) get(out interface{}, path ...string) error {
+	if cl.err != nil {
+		return cl.err
+	}
+	endpoint, err := url.JoinPath(cl.baseURL, path...)
+	if err != nil {
+		return fmt.Errorf("failed to join url path: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build http request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+	cl.config.authorize(req)
+
+	resp, err := cl.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot connect to Schema Registry: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("unable to read response body: %w", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w at %s: %s", errSchemaNotFound, endpoint, body)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d from %s: %s", resp.StatusCode, endpoint, body)
+	}
+	return json.Unmarshal(body, out)
+}
+
 // getSchema retrieves the schema for a given schema ID from the Schema Registry.
 //
+// Any schema references are resolved recursively and inlined, so the returned schema
+// can be compiled on its own.
+//
 // Parameters:
 // - schemaID: The ID of the schema to retrieve.
 // - registryURL: The URL of the Schema Registry.
@@ -17,20 +126,43 @@ import (
 // - string: The retrieved schema.
 // - error: An error if the schema retrieval fails.
 func getSchema(schemaID string, registryURL string) (string, error) {
-	registry, err := sr.NewClient(registryURL)
-	if err != nil {
-		return "", fmt.Errorf("cannot connect to Schema Registry: %w", err)
-	}
+	registry := newSchemaRegistryClient(registryURL)
 
 	schemaIDInt, err := strconv.Atoi(schemaID)
 	if err != nil {
 		return "", fmt.Errorf("schema ID not an integer: %w", err)
 	}
 
-	schema, err := registry.GetSchemaByID(schemaIDInt)
+	schema, err := registry.schemaByID(schemaIDInt)
 	if err != nil {
 		return "", fmt.Errorf("unable to retrieve schema for ID %s: %w", schemaID, err)
 	}
 
-	return schema, nil
+	return utils.ResolveAvroReferences(schema.Schema, schema.References, registry.resolveReference)
+}
+
+// destinationSchemaID resolves the destination schema ID from the environment.
+func (cl *schemaRegistryClient) destinationSchemaID() (int, error) {
+	destination, err := utils.DestinationSchemaFromEnv()
+	if err != nil {
+		return 0, err
+	}
+	schemaID, err := destination.Resolve(func(subject string, version int) (int, error) {
+		schema, err := cl.schemaByVersion(subject, version)
+		return schema.ID, err
+	})
+	if err != nil {
+		return 0, err
+	}
+	slog.Info("Resolved destination schema", "destination", destination.String(), "schemaID", schemaID)
+	return schemaID, nil
+}
+
+// resolveReference fetches a referenced schema by subject and version.
+func (cl *schemaRegistryClient) resolveReference(subject string, version int) (string, []utils.SchemaReference, error) {
+	schema, err := cl.schemaByVersion(subject, version)
+	if err != nil {
+		return "", nil, err
+	}
+	return schema.Schema, schema.References, nil
 }
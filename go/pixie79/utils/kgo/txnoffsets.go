@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// commitTransactionOffsets adds consumer group offsets to the open transaction of a transactional
+// client, so they are committed or aborted with the records produced in it. The group is not joined:
+// the offsets are committed for it as for a consumer assigning its own partitions.
+func commitTransactionOffsets(ctx context.Context, client *kgo.Client, transactionalID, group string, offsets kadm.Offsets) error {
+	producerID, epoch, err := client.ProducerID(ctx)
+	if err != nil {
+		return err
+	}
+
+	add := kmsg.NewPtrAddOffsetsToTxnRequest()
+	add.TransactionalID = transactionalID
+	add.ProducerID = producerID
+	add.ProducerEpoch = epoch
+	add.Group = group
+	addResp, err := add.RequestWith(ctx, client)
+	if err != nil {
+		return fmt.Errorf("unable to add the offsets of group %s to the transaction: %w", group, err)
+	}
+	if err := kerr.ErrorForCode(addResp.ErrorCode); err != nil {
+		return fmt.Errorf("unable to add the offsets of group %s to the transaction: %w", group, err)
+	}
+
+	commit := kmsg.NewPtrTxnOffsetCommitRequest()
+	commit.TransactionalID = transactionalID
+	commit.ProducerID = producerID
+	commit.ProducerEpoch = epoch
+	commit.Group = group
+	commit.Generation = -1
+	topics := map[string]int{}
+	for _, offset := range offsets.Sorted() {
+		index, ok := topics[offset.Topic]
+		if !ok {
+			index = len(commit.Topics)
+			topics[offset.Topic] = index
+			topic := kmsg.NewTxnOffsetCommitRequestTopic()
+			topic.Topic = offset.Topic
+			commit.Topics = append(commit.Topics, topic)
+		}
+		partition := kmsg.NewTxnOffsetCommitRequestTopicPartition()
+		partition.Partition = offset.Partition
+		partition.Offset = offset.At
+		partition.LeaderEpoch = offset.LeaderEpoch
+		commit.Topics[index].Partitions = append(commit.Topics[index].Partitions, partition)
+	}
+	commitResp, err := commit.RequestWith(ctx, client)
+	if err != nil {
+		return fmt.Errorf("unable to commit the offsets of group %s in the transaction: %w", group, err)
+	}
+	for _, topic := range commitResp.Topics {
+		for _, partition := range topic.Partitions {
+			if err := kerr.ErrorForCode(partition.ErrorCode); err != nil {
+				return fmt.Errorf("unable to commit the offset of %s partition %d for group %s: %w", topic.Topic, partition.Partition, group, err)
+			}
+		}
+	}
+	return nil
+}
@@ -4,8 +4,11 @@ import (
 	"encoding/json"
 	"errors"
 	"log/slog"
+	"reflect"
+	"sync"
 
 	pTypes "pixie79/types"
+	"pixie79/utils"
 	pTransform "pixie79/utils/transforms"
 
 	"github.com/linkedin/goavro/v2"
@@ -18,50 +21,102 @@ type AvroConverter interface {
 	ConvertToAvroRecord() (map[string]interface{}, error)
 }
 
+// ConvertToAvroKgoRecords converts a JSON array of events to Avro records for the destination codec,
+// using the converter registered for the event type in pixie79/types.
 func ConvertToAvroKgoRecords(eventType string, jsonData []byte, hdr []byte, codec *goavro.Codec, key []byte, headers []transform.RecordHeader, topic string) ([]*kgo.Record, error) {
-	var (
-		converter AvroConverter
-		records   []*kgo.Record
-	)
-
-	switch eventType {
+	var events []json.RawMessage
+	if err := json.Unmarshal(jsonData, &events); err != nil {
+		return nil, err
+	}
 
-	case "demoEvent":
-		var events []pTypes.DemoEvent
-		if err := json.Unmarshal(jsonData, &events); err != nil {
+	records := make([]*kgo.Record, 0, len(events))
+	for _, event := range events {
+		record, err := ConvertToAvroKgoRecord(eventType, event, hdr, codec, key, headers, topic)
+		if err != nil {
 			return nil, err
 		}
-		for _, event := range events {
-			converter = &pTypes.DemoEventConverter{Event: event}
-			record, err := convertToAvroKgoRecord(converter, hdr, codec, key, headers, topic)
-			if err != nil {
-				return nil, err
-			}
-			records = append(records, record)
-		}
-		return records, nil
-
-	default:
-		return nil, errors.New("unsupported event type")
+		records = append(records, record)
 	}
+	return records, nil
 }
 
+// ConvertToAvroKgoRecord converts a single JSON event to an Avro record for the destination codec.
 func ConvertToAvroKgoRecord(eventType string, jsonData []byte, hdr []byte, codec *goavro.Codec, key []byte, headers []transform.RecordHeader, topic string) (*kgo.Record, error) {
-	var converter AvroConverter
-	switch eventType {
+	converter, err := eventConverter(eventType, jsonData, codec)
+	if err != nil {
+		return nil, err
+	}
+	return convertToAvroKgoRecord(converter, hdr, codec, key, headers, topic)
+}
 
-	case "demoEvent":
-		var event pTypes.DemoEvent
-		err := json.Unmarshal(jsonData, &event)
-		if err != nil {
-			return nil, err
-		}
-		converter = &pTypes.DemoEventConverter{Event: event}
-		return convertToAvroKgoRecord(converter, hdr, codec, key, headers, topic)
+// eventConverter returns the converter for a single JSON event: the registered Go type for the event
+// type, or the destination schema itself when no Go type is registered.
+func eventConverter(eventType string, event []byte, codec *goavro.Codec) (AvroConverter, error) {
+	registered, ok := pTypes.LookupEventType(eventType)
+	if ok && registered.Convert != nil {
+		return &registeredConverter{eventType: registered, event: event}, nil
+	}
+
+	schema, err := codecSchema(codec)
+	if err != nil {
+		return nil, err
+	}
+	if ok && registered.Type != nil {
+		return &structConverter{schema: schema, eventType: registered.Type, event: event}, nil
+	}
+	return &schemaConverter{schema: schema, event: event}, nil
+}
+
+// registeredConverter converts a single JSON event with an event type from the types registry.
+type registeredConverter struct {
+	eventType pTypes.EventType
+	event     []byte
+}
+
+func (c *registeredConverter) ConvertToAvroRecord() (map[string]interface{}, error) {
+	return c.eventType.Convert(c.event)
+}
+
+// structConverter decodes a single JSON event into the registered Go type and converts it from its
+// avro struct tags.
+type structConverter struct {
+	schema    *utils.AvroSchema
+	eventType reflect.Type
+	event     []byte
+}
+
+func (c *structConverter) ConvertToAvroRecord() (map[string]interface{}, error) {
+	event := reflect.New(c.eventType)
+	if err := json.Unmarshal(c.event, event.Interface()); err != nil {
+		return nil, err
+	}
+	native, err := utils.AvroNativeFromStruct(c.schema, event.Interface())
+	if err != nil {
+		return nil, err
+	}
+	return nativeRecord(native)
+}
+
+// schemaConverter converts a single JSON event using only the destination schema.
+type schemaConverter struct {
+	schema *utils.AvroSchema
+	event  []byte
+}
 
-	default:
-		return nil, errors.New("unsupported event type")
+func (c *schemaConverter) ConvertToAvroRecord() (map[string]interface{}, error) {
+	native, err := utils.AvroNativeFromJSON(c.schema, c.event)
+	if err != nil {
+		return nil, err
 	}
+	return nativeRecord(native)
+}
+
+func nativeRecord(native interface{}) (map[string]interface{}, error) {
+	record, ok := native.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("destination schema is not a record")
+	}
+	return record, nil
 }
 
 func convertToAvroKgoRecord(converter AvroConverter, hdr []byte, codec *goavro.Codec, key []byte, headers []transform.RecordHeader, topic string) (*kgo.Record, error) {
@@ -70,19 +125,43 @@ func convertToAvroKgoRecord(converter AvroConverter, hdr []byte, codec *goavro.C
 		return nil, err
 	}
 
-	encodedRecord, err := pTransform.EncodeAvroRecord(avroRecord, codec, hdr, key, headers)
+	schema, err := codecSchema(codec)
 	if err != nil {
-		slog.Error("Error encoding Avro record", "Error", err)
+		return nil, err
+	}
+	wrapped, err := utils.WrapAvroUnions(schema, avroRecord)
+	if err != nil {
+		return nil, err
 	}
 
-	kgoHeaders := make([]kgo.RecordHeader, len(encodedRecord.Headers))
+	encodedRecord, err := pTransform.EncodeAvroRecord(wrapped.(map[string]interface{}), codec, hdr, key, headers)
+	if err != nil {
+		slog.Error("Error encoding Avro record", "Error", err)
+		return nil, err
+	}
 
 	r := &kgo.Record{
 		Key:     encodedRecord.Key,
 		Value:   encodedRecord.Value,
-		Headers: kgoHeaders,
+		Headers: KgoHeaders(encodedRecord.Headers),
 		Topic:   topic,
 	}
 
 	return r, nil
 }
+
+// codecSchemas caches the parsed schema of each destination codec, so union values are wrapped
+// without parsing the schema again for every record.
+var codecSchemas sync.Map
+
+func codecSchema(codec *goavro.Codec) (*utils.AvroSchema, error) {
+	if schema, ok := codecSchemas.Load(codec); ok {
+		return schema.(*utils.AvroSchema), nil
+	}
+	schema, err := utils.ParseAvroSchema(codec.Schema())
+	if err != nil {
+		return nil, err
+	}
+	codecSchemas.Store(codec, schema)
+	return schema, nil
+}
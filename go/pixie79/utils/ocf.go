@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+
+	goavro "github.com/linkedin/goavro/v2"
+)
+
+// ocfMagic starts every Avro object container file.
+var ocfMagic = []byte("Obj\x01")
+
+// singleObjectMagic starts every Avro single-object encoded value, followed by the little-endian
+// Rabin fingerprint of the writer schema.
+var singleObjectMagic = []byte{0xC3, 0x01}
+
+// IsOCF reports whether data starts like an Avro object container file.
+func IsOCF(data []byte) bool {
+	return bytes.HasPrefix(data, ocfMagic)
+}
+
+// ReadOCF reads every record of an Avro object container file and returns them as goavro native
+// values, along with the writer schema stored in the file header.
+func ReadOCF(r io.Reader) (string, []interface{}, error) {
+	reader, err := goavro.NewOCFReader(r)
+	if err != nil {
+		slog.Error("Error reading OCF header", "Error", err)
+		return "", nil, err
+	}
+
+	var records []interface{}
+	for reader.Scan() {
+		record, err := reader.Read()
+		if err != nil {
+			slog.Error("Error reading OCF record", "record", len(records), "Error", err)
+			return "", nil, err
+		}
+		records = append(records, record)
+	}
+	if err := reader.Err(); err != nil {
+		slog.Error("Error reading OCF block", "Error", err)
+		return "", nil, err
+	}
+	return reader.Codec().Schema(), records, nil
+}
+
+// WriteOCF writes goavro native records as an Avro object container file. Compression is one of
+// null, deflate or snappy, and an empty compression writes uncompressed blocks.
+func WriteOCF(w io.Writer, schema string, records []interface{}, compression string) error {
+	writer, err := goavro.NewOCFWriter(goavro.OCFConfig{
+		W:               w,
+		Schema:          schema,
+		CompressionName: compression,
+	})
+	if err != nil {
+		slog.Error("Error creating OCF writer", "Error", err)
+		return err
+	}
+	if err := writer.Append(records); err != nil {
+		slog.Error("Error writing OCF records", "Error", err)
+		return err
+	}
+	return nil
+}
+
+// IsSingleObject reports whether value starts with the Avro single-object encoding marker.
+func IsSingleObject(value []byte) bool {
+	return len(value) >= len(singleObjectMagic)+8 && bytes.HasPrefix(value, singleObjectMagic)
+}
+
+// DecodeSingleObject splits an Avro single-object encoded value into the Rabin fingerprint of the
+// writer schema and the binary Avro payload.
+func DecodeSingleObject(value []byte) (uint64, []byte, error) {
+	fingerprint, payload, err := goavro.FingerprintFromSOE(value)
+	if err != nil {
+		return 0, nil, fmt.Errorf("value is not single-object encoded: %w", err)
+	}
+	return fingerprint, payload, nil
+}
+
+// EncodeSingleObject encodes a goavro native value with the single-object encoding of the codec's schema.
+func EncodeSingleObject(codec *goavro.Codec, datum interface{}) ([]byte, error) {
+	return codec.SingleFromNative(nil, datum)
+}
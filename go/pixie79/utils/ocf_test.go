@@ -0,0 +1,63 @@
+package utils_test
+
+import (
+	"bytes"
+
+	"pixie79/utils"
+
+	. "github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+)
+
+var _ = Describe("Avro object container files", func() {
+	It("should round trip records and the writer schema", func() {
+		codec := demoCodec(GinkgoT())
+		var file bytes.Buffer
+		err := utils.WriteOCF(&file, codec.Schema(), []interface{}{demoNative, demoNative}, "deflate")
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(utils.IsOCF(file.Bytes())).To(gomega.BeTrue())
+
+		schema, records, err := utils.ReadOCF(&file)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(schema).To(gomega.Equal(codec.Schema()))
+		gomega.Expect(records).To(gomega.HaveLen(2))
+
+		lastName, _ := utils.GetStringField(records[1].(map[string]interface{}), "payload.last_name")
+		gomega.Expect(lastName).To(gomega.Equal("Jones"))
+	})
+
+	It("should reject an unknown compression codec", func() {
+		codec := demoCodec(GinkgoT())
+		err := utils.WriteOCF(&bytes.Buffer{}, codec.Schema(), nil, "lz4")
+		gomega.Expect(err).To(gomega.HaveOccurred())
+	})
+
+	It("should not mistake JSON for a container file", func() {
+		gomega.Expect(utils.IsOCF([]byte(`[{"metadata": {}}]`))).To(gomega.BeFalse())
+	})
+})
+
+var _ = Describe("Avro single-object encoding", func() {
+	It("should expose the writer schema fingerprint and payload", func() {
+		codec := demoCodec(GinkgoT())
+		value, err := utils.EncodeSingleObject(codec, demoNative)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(utils.IsSingleObject(value)).To(gomega.BeTrue())
+
+		fingerprint, payload, err := utils.DecodeSingleObject(value)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(fingerprint).To(gomega.Equal(codec.Rabin))
+
+		nestedMap, err := utils.DecodeAvroWithCodec(codec, payload)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		lastName, _ := utils.GetStringField(nestedMap, "payload.last_name")
+		gomega.Expect(lastName).To(gomega.Equal("Jones"))
+	})
+
+	It("should not treat schema registry wire format as single-object encoded", func() {
+		value := demoWireValue(GinkgoT(), demoCodec(GinkgoT()))
+		gomega.Expect(utils.IsSingleObject(value)).To(gomega.BeFalse())
+		_, _, err := utils.DecodeSingleObject(value)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+	})
+})
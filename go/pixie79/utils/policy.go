@@ -0,0 +1,205 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Masking actions supported by a MaskingRule. Redact writes a null and is only valid for nullable fields.
+const (
+	ActionMask     = "mask"
+	ActionTokenise = "tokenise"
+	ActionRedact   = "redact"
+	ActionNone     = "none"
+)
+
+// DefaultJurisdictionField is the record field used to select a jurisdiction when a policy does not set one.
+const DefaultJurisdictionField = "payload.country_of_residence"
+
+// MaskingRule describes how a single field of a record is treated.
+type MaskingRule struct {
+	Field    string `json:"field"`               // Dotted path to the field, e.g. "payload.given_name"
+	Action   string `json:"action"`              // One of mask, tokenise, redact or none
+	MaskChar string `json:"mask_char,omitempty"` // Character used by the mask action, defaults to "*"
+	Option   string `json:"option,omitempty"`    // MaskString option: first, last or fixed (default)
+	Count    *int   `json:"count,omitempty"`     // MaskString count, defaults to 6 when not set
+}
+
+// MaskingPolicy selects a rule set per jurisdiction based on a field of the record.
+//
+// Jurisdictions are matched case-insensitively against the value of JurisdictionField;
+// records with no value, or a value without a rule set, use the Default rules.
+type MaskingPolicy struct {
+	JurisdictionField string                   `json:"jurisdiction_field,omitempty"`
+	Jurisdictions     map[string][]MaskingRule `json:"jurisdictions,omitempty"`
+	Default           []MaskingRule            `json:"default"`
+	// TokeniseKey keys the HMAC of the tokenise action, required when any rule tokenises
+	TokeniseKey string `json:"-"`
+}
+
+// DefaultMaskingPolicy returns the policy used when none is configured: the given and last
+// names are masked with a fixed six character mask for every jurisdiction.
+func DefaultMaskingPolicy() *MaskingPolicy {
+	return &MaskingPolicy{
+		JurisdictionField: DefaultJurisdictionField,
+		Default: []MaskingRule{
+			{Field: "payload.given_name", Action: ActionMask},
+			{Field: "payload.last_name", Action: ActionMask},
+		},
+	}
+}
+
+// UnmarshalMaskingPolicy parses and validates a JSON masking policy, using tokeniseKey for its tokenise rules.
+func UnmarshalMaskingPolicy(data string, tokeniseKey string) (*MaskingPolicy, error) {
+	policy := MaskingPolicy{TokeniseKey: tokeniseKey}
+	if err := json.Unmarshal([]byte(data), &policy); err != nil {
+		slog.Error("Error unmarshalling masking policy", "Error", err)
+		return nil, err
+	}
+
+	if policy.JurisdictionField == "" {
+		policy.JurisdictionField = DefaultJurisdictionField
+	}
+
+	// Normalise jurisdiction keys so lookups are case-insensitive
+	jurisdictions := make(map[string][]MaskingRule, len(policy.Jurisdictions))
+	for name, rules := range policy.Jurisdictions {
+		jurisdictions[strings.ToUpper(name)] = rules
+	}
+	policy.Jurisdictions = jurisdictions
+
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// Validate checks every rule in the policy has a field, a known action, a known mask option and a
+// count that is not negative, that key fields are only tokenised or left as they are, and that a
+// tokenise key is set when any rule tokenises.
+func (p *MaskingPolicy) Validate() error {
+	check := func(jurisdiction string, rules []MaskingRule) error {
+		for i, rule := range rules {
+			if rule.Field == "" {
+				return fmt.Errorf("masking policy %s rule %d: field is required", jurisdiction, i)
+			}
+			switch rule.Action {
+			case ActionMask, ActionTokenise, ActionRedact, ActionNone:
+			default:
+				return fmt.Errorf("masking policy %s rule %d: unknown action %q", jurisdiction, i, rule.Action)
+			}
+			// masked keys would all collide and redacted keys break non-nullable key schemas
+			if strings.HasPrefix(rule.Field, KeyFieldPrefix) && rule.Action != ActionTokenise && rule.Action != ActionNone {
+				return fmt.Errorf("masking policy %s rule %d: key field %s only supports the tokenise and none actions", jurisdiction, i, rule.Field)
+			}
+			if rule.Action == ActionTokenise && p.TokeniseKey == "" {
+				return fmt.Errorf("masking policy %s rule %d: tokenise requires a tokenise key", jurisdiction, i)
+			}
+			if rule.Count != nil && *rule.Count < 0 {
+				return fmt.Errorf("masking policy %s rule %d: count must not be negative, got %d", jurisdiction, i, *rule.Count)
+			}
+			switch rule.Option {
+			case "", "first", "last", "fixed":
+			default:
+				return fmt.Errorf("masking policy %s rule %d: unknown option %q, expected first, last or fixed", jurisdiction, i, rule.Option)
+			}
+		}
+		return nil
+	}
+
+	if err := check("default", p.Default); err != nil {
+		return err
+	}
+	for name, rules := range p.Jurisdictions {
+		if err := check(name, rules); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LogValue logs the policy without its tokenise key.
+func (p *MaskingPolicy) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("jurisdiction_field", p.JurisdictionField),
+		slog.Any("jurisdictions", p.Jurisdictions),
+		slog.Any("default", p.Default),
+	)
+}
+
+// RulesFor returns the jurisdiction selected for the record and the rules that apply to it.
+func (p *MaskingPolicy) RulesFor(record map[string]interface{}) (string, []MaskingRule) {
+	jurisdiction, ok := GetStringField(record, p.JurisdictionField)
+	if ok && jurisdiction != "" {
+		if rules, found := p.Jurisdictions[strings.ToUpper(jurisdiction)]; found {
+			return strings.ToUpper(jurisdiction), rules
+		}
+	}
+	return "default", p.Default
+}
+
+// Apply masks the record in place using the rules for its jurisdiction and returns the jurisdiction used.
+func (p *MaskingPolicy) Apply(record map[string]interface{}) string {
+	jurisdiction, rules := p.RulesFor(record)
+	for _, rule := range rules {
+		if !strings.HasPrefix(rule.Field, KeyFieldPrefix) {
+			p.applyMaskingRule(record, rule)
+		}
+	}
+	slog.Debug("Applied masking policy", "jurisdiction", jurisdiction, "rules", len(rules))
+	return jurisdiction
+}
+
+// ApplyToKey masks a decoded record key in place using the rules whose field starts with "key.", for
+// the jurisdiction selected from the record value.
+func (p *MaskingPolicy) ApplyToKey(record map[string]interface{}, key map[string]interface{}) {
+	_, rules := p.RulesFor(record)
+	for _, rule := range rules {
+		if strings.HasPrefix(rule.Field, KeyFieldPrefix) {
+			rule.Field = strings.TrimPrefix(rule.Field, KeyFieldPrefix)
+			p.applyMaskingRule(key, rule)
+		}
+	}
+}
+
+func (p *MaskingPolicy) applyMaskingRule(record map[string]interface{}, rule MaskingRule) {
+	value, ok := GetStringField(record, rule.Field)
+	if !ok {
+		slog.Debug("Field not available for masking", "field", rule.Field)
+		return
+	}
+
+	switch rule.Action {
+	case ActionMask:
+		maskChar, option, count := rule.MaskChar, rule.Option, 6
+		if maskChar == "" {
+			maskChar = "*"
+		}
+		if option == "" {
+			option = "fixed"
+		}
+		if rule.Count != nil {
+			count = *rule.Count
+		}
+		SetField(record, rule.Field, MaskString(value, maskChar, option, count))
+	case ActionTokenise:
+		SetField(record, rule.Field, TokeniseString(p.TokeniseKey, value))
+	case ActionRedact:
+		SetField(record, rule.Field, nil)
+	case ActionNone:
+	}
+}
+
+// TokeniseString replaces s with a deterministic token so that equal values can still be joined downstream.
+// The token is an HMAC-SHA256 keyed with key, so values cannot be recovered by hashing candidates
+// without the key.
+func TokeniseString(key, s string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(s))
+	return "tok_" + hex.EncodeToString(mac.Sum(nil))[:32]
+}
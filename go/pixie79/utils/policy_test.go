@@ -0,0 +1,122 @@
+package utils_test
+
+import (
+	"pixie79/utils"
+
+	. "github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+)
+
+var _ = Describe("MaskingPolicy", func() {
+	var (
+		record map[string]interface{}
+		policy *utils.MaskingPolicy
+		err    error
+	)
+
+	const policyJSON = `{
+		"jurisdictions": {
+			"uk": [
+				{"field": "payload.given_name", "action": "mask"},
+				{"field": "payload.last_name", "action": "mask"},
+				{"field": "payload.place_of_birth", "action": "redact"}
+			],
+			"USA": [
+				{"field": "payload.last_name", "action": "tokenise"}
+			]
+		},
+		"default": [
+			{"field": "payload.last_name", "action": "mask", "option": "first", "count": 1}
+		]
+	}`
+
+	newRecord := func(country interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"payload": map[string]interface{}{
+				"id":                   "PK123",
+				"given_name":           map[string]interface{}{"string": "Tom"},
+				"last_name":            map[string]interface{}{"string": "Jones"},
+				"place_of_birth":       map[string]interface{}{"string": "Sydney"},
+				"country_of_residence": country,
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		policy, err = utils.UnmarshalMaskingPolicy(policyJSON, "secret")
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+	})
+
+	Context("when the jurisdiction has a rule set", func() {
+		It("should fully mask UK residents", func() {
+			record = newRecord(map[string]interface{}{"string": "UK"})
+			gomega.Expect(policy.Apply(record)).To(gomega.Equal("UK"))
+			payload := record["payload"].(map[string]interface{})
+			gomega.Expect(payload["given_name"]).To(gomega.Equal(map[string]interface{}{"string": "******"}))
+			gomega.Expect(payload["last_name"]).To(gomega.Equal(map[string]interface{}{"string": "******"}))
+			gomega.Expect(payload["place_of_birth"]).To(gomega.BeNil())
+		})
+
+		It("should tokenise USA residents deterministically", func() {
+			record = newRecord(map[string]interface{}{"string": "usa"})
+			gomega.Expect(policy.Apply(record)).To(gomega.Equal("USA"))
+			lastName, ok := utils.GetStringField(record, "payload.last_name")
+			gomega.Expect(ok).To(gomega.BeTrue())
+			gomega.Expect(lastName).To(gomega.Equal(utils.TokeniseString("secret", "Jones")))
+			gomega.Expect(lastName).NotTo(gomega.Equal(utils.TokeniseString("other", "Jones")))
+			givenName, _ := utils.GetStringField(record, "payload.given_name")
+			gomega.Expect(givenName).To(gomega.Equal("Tom"))
+		})
+	})
+
+	Context("when the jurisdiction is unknown or null", func() {
+		It("should fall back to the default rules", func() {
+			for _, country := range []interface{}{nil, map[string]interface{}{"string": "Australia"}} {
+				record = newRecord(country)
+				gomega.Expect(policy.Apply(record)).To(gomega.Equal("default"))
+				lastName, _ := utils.GetStringField(record, "payload.last_name")
+				gomega.Expect(lastName).To(gomega.Equal("J****"))
+			}
+		})
+
+		It("should use an explicit count of zero", func() {
+			policy, err = utils.UnmarshalMaskingPolicy(`{"default": [{"field": "payload.last_name", "action": "mask", "option": "first", "count": 0}]}`, "")
+			gomega.Expect(err).NotTo(gomega.HaveOccurred())
+			record = newRecord(nil)
+			policy.Apply(record)
+			lastName, _ := utils.GetStringField(record, "payload.last_name")
+			gomega.Expect(lastName).To(gomega.Equal("*****"))
+		})
+	})
+
+	Context("when the policy is invalid", func() {
+		It("should reject unknown actions", func() {
+			_, err = utils.UnmarshalMaskingPolicy(`{"default": [{"field": "payload.id", "action": "shred"}]}`, "")
+			gomega.Expect(err).To(gomega.HaveOccurred())
+		})
+
+		It("should reject unknown mask options", func() {
+			_, err = utils.UnmarshalMaskingPolicy(`{"default": [{"field": "payload.id", "action": "mask", "option": "middle"}]}`, "")
+			gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring(`unknown option "middle"`)))
+		})
+
+		It("should reject negative mask counts", func() {
+			_, err = utils.UnmarshalMaskingPolicy(`{"default": [{"field": "payload.id", "action": "mask", "option": "first", "count": -1}]}`, "")
+			gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("count must not be negative, got -1")))
+		})
+
+		It("should require a key for tokenise rules", func() {
+			_, err = utils.UnmarshalMaskingPolicy(`{"default": [{"field": "payload.id", "action": "tokenise"}]}`, "")
+			gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("tokenise requires a tokenise key")))
+		})
+
+		It("should only allow key fields to be tokenised or left unchanged", func() {
+			for _, action := range []string{"mask", "redact"} {
+				_, err = utils.UnmarshalMaskingPolicy(`{"default": [{"field": "key.id", "action": "`+action+`"}]}`, "")
+				gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("key field key.id only supports the tokenise and none actions")))
+			}
+			_, err = utils.UnmarshalMaskingPolicy(`{"default": [{"field": "key.id", "action": "tokenise"}, {"field": "key.name", "action": "none"}]}`, "secret")
+			gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// protobufRootFile is the name the registered schema is compiled under; references use their own names.
+const protobufRootFile = "schema-registry-root.proto"
+
+// ProtobufSchema is a compiled schema registry Protobuf schema.
+type ProtobufSchema struct {
+	File protoreflect.FileDescriptor
+}
+
+// CompileProtobufSchema compiles a .proto schema. References maps import paths to the source of every
+// file the schema imports, directly or transitively; the well known types are always available.
+func CompileProtobufSchema(schema string, references map[string]string) (*ProtobufSchema, error) {
+	sources := map[string]string{protobufRootFile: schema}
+	for name, source := range references {
+		sources[name] = source
+	}
+
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			Accessor: protocompile.SourceAccessorFromMap(sources),
+		}),
+	}
+	files, err := compiler.Compile(context.Background(), protobufRootFile)
+	if err != nil {
+		slog.Error("Error compiling Protobuf schema", "Error", err)
+		return nil, err
+	}
+	return &ProtobufSchema{File: files[0]}, nil
+}
+
+// MessageByIndexes returns the message addressed by a wire-format message-index array: the first index
+// selects a top-level message and each following index a nested message within it.
+func (s *ProtobufSchema) MessageByIndexes(indexes []int) (protoreflect.MessageDescriptor, error) {
+	if len(indexes) == 0 {
+		indexes = []int{0}
+	}
+
+	messages := s.File.Messages()
+	var descriptor protoreflect.MessageDescriptor
+	for _, index := range indexes {
+		if index < 0 || index >= messages.Len() {
+			return nil, fmt.Errorf("message index %v not found in schema", indexes)
+		}
+		descriptor = messages.Get(index)
+		messages = descriptor.Messages()
+	}
+	return descriptor, nil
+}
+
+// MessageByName returns the message with the given fully qualified name along with its message-index array.
+// An empty name selects the first message in the schema.
+func (s *ProtobufSchema) MessageByName(name string) (protoreflect.MessageDescriptor, []int, error) {
+	if name == "" {
+		descriptor, err := s.MessageByIndexes(nil)
+		return descriptor, []int{0}, err
+	}
+
+	var search func(messages protoreflect.MessageDescriptors, path []int) (protoreflect.MessageDescriptor, []int)
+	search = func(messages protoreflect.MessageDescriptors, path []int) (protoreflect.MessageDescriptor, []int) {
+		for i := 0; i < messages.Len(); i++ {
+			message := messages.Get(i)
+			indexes := append(append([]int{}, path...), i)
+			if string(message.FullName()) == name {
+				return message, indexes
+			}
+			if found, foundIndexes := search(message.Messages(), indexes); found != nil {
+				return found, foundIndexes
+			}
+		}
+		return nil, nil
+	}
+
+	descriptor, indexes := search(s.File.Messages(), nil)
+	if descriptor == nil {
+		return nil, nil, fmt.Errorf("message %s not found in schema", name)
+	}
+	return descriptor, indexes, nil
+}
+
+// DecodeProtobufBuffer parses the Protobuf wire-format header: the magic byte, the schema ID and the
+// message-index array. A single zero byte is the shorthand for the first message, [0].
+func DecodeProtobufBuffer(value []byte) (int, []int, []byte, error) {
+	schemaID, payload, err := DecodeBuffer(value)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	count, n := binary.Varint(payload)
+	if n <= 0 {
+		return 0, nil, nil, fmt.Errorf("%w: invalid message-index array length", ErrInvalidWireFormat)
+	}
+	payload = payload[n:]
+	if count == 0 {
+		return schemaID, []int{0}, payload, nil
+	}
+	if count < 0 || count > int64(len(payload)) {
+		return 0, nil, nil, fmt.Errorf("%w: invalid message-index array length %d", ErrInvalidWireFormat, count)
+	}
+
+	indexes := make([]int, count)
+	for i := range indexes {
+		index, n := binary.Varint(payload)
+		if n <= 0 {
+			return 0, nil, nil, fmt.Errorf("%w: invalid message index", ErrInvalidWireFormat)
+		}
+		indexes[i] = int(index)
+		payload = payload[n:]
+	}
+	return schemaID, indexes, payload, nil
+}
+
+// EncodeProtobufBuffer returns the Protobuf wire-format header for the schema ID and message-index array.
+func EncodeProtobufBuffer(schemaID int, indexes []int) []byte {
+	header := EncodeBuffer(schemaID)
+	if len(indexes) == 0 || (len(indexes) == 1 && indexes[0] == 0) {
+		return append(header, 0)
+	}
+	header = binary.AppendVarint(header, int64(len(indexes)))
+	for _, index := range indexes {
+		header = binary.AppendVarint(header, int64(index))
+	}
+	return header
+}
+
+// DecodeProtobuf decodes a binary Protobuf payload into the same generic nested map used for Avro records,
+// keyed by the field names in the .proto file, so the masking logic applies unchanged.
+func DecodeProtobuf(descriptor protoreflect.MessageDescriptor, payload []byte) (map[string]interface{}, error) {
+	message := dynamicpb.NewMessage(descriptor)
+	if err := proto.Unmarshal(payload, message); err != nil {
+		slog.Error("Error unmarshalling Protobuf", "Error", err)
+		return nil, err
+	}
+
+	jsonBytes, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+
+	var nestedMap map[string]interface{}
+	if err := json.Unmarshal(jsonBytes, &nestedMap); err != nil {
+		return nil, err
+	}
+	return nestedMap, nil
+}
+
+// EncodeProtobuf encodes a generic nested map, or any JSON document, as a binary Protobuf payload.
+func EncodeProtobuf(descriptor protoreflect.MessageDescriptor, nestedMap map[string]interface{}) ([]byte, error) {
+	jsonBytes, err := json.Marshal(nestedMap)
+	if err != nil {
+		return nil, err
+	}
+	return EncodeProtobufJSON(descriptor, jsonBytes)
+}
+
+// EncodeProtobufJSON encodes a JSON document as a binary Protobuf payload.
+func EncodeProtobufJSON(descriptor protoreflect.MessageDescriptor, jsonBytes []byte) ([]byte, error) {
+	if descriptor == nil {
+		return nil, errors.New("protobuf message descriptor is required")
+	}
+	message := dynamicpb.NewMessage(descriptor)
+	if err := protojson.Unmarshal(jsonBytes, message); err != nil {
+		slog.Error("Error converting JSON to Protobuf", "Error", err)
+		return nil, err
+	}
+	return proto.Marshal(message)
+}
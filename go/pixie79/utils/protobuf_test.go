@@ -0,0 +1,90 @@
+package utils_test
+
+import (
+	"pixie79/utils"
+
+	. "github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+)
+
+var _ = Describe("Protobuf", func() {
+	const (
+		metadataProto = `
+			syntax = "proto3";
+			package com.demo.common;
+			message EventMetadata {
+				string message_key = 1;
+				string event_type = 2;
+			}`
+		customerProto = `
+			syntax = "proto3";
+			package com.demo.event.v1;
+			import "common/metadata.proto";
+			message Ignored {}
+			message CustomerEvent {
+				com.demo.common.EventMetadata metadata = 1;
+				Customer payload = 2;
+				message Customer {
+					string id = 1;
+					string given_name = 2;
+					string last_name = 3;
+					string country_of_residence = 4;
+				}
+			}`
+	)
+
+	It("should round trip message indexes through the wire-format header", func() {
+		for _, indexes := range [][]int{{0}, {1}, {1, 0}, {3, 2, 1}} {
+			value := append(utils.EncodeProtobufBuffer(99, indexes), 0x0a)
+			id, decoded, payload, err := utils.DecodeProtobufBuffer(value)
+			gomega.Expect(err).NotTo(gomega.HaveOccurred())
+			gomega.Expect(id).To(gomega.Equal(99))
+			gomega.Expect(decoded).To(gomega.Equal(indexes))
+			gomega.Expect(payload).To(gomega.Equal([]byte{0x0a}))
+		}
+	})
+
+	It("should use the single zero byte shorthand for the first message", func() {
+		gomega.Expect(utils.EncodeProtobufBuffer(1, []int{0})).To(gomega.Equal([]byte{0, 0, 0, 0, 1, 0}))
+	})
+
+	It("should decode into a generic map the masking policy can use", func() {
+		schema, err := utils.CompileProtobufSchema(customerProto, map[string]string{"common/metadata.proto": metadataProto})
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+		descriptor, indexes, err := schema.MessageByName("com.demo.event.v1.CustomerEvent")
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(indexes).To(gomega.Equal([]int{1}))
+
+		encoded, err := utils.EncodeProtobufJSON(descriptor, []byte(`{
+			"metadata": {"message_key": "tnKGDKUndl", "event_type": "INSERT"},
+			"payload": {"id": "PK1", "given_name": "Tom", "last_name": "Jones", "country_of_residence": "UK"}
+		}`))
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+		id, decodedIndexes, payload, err := utils.DecodeProtobufBuffer(append(utils.EncodeProtobufBuffer(7, indexes), encoded...))
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(id).To(gomega.Equal(7))
+		message, err := schema.MessageByIndexes(decodedIndexes)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+		nestedMap, err := utils.DecodeProtobuf(message, payload)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		utils.DefaultMaskingPolicy().Apply(nestedMap)
+
+		reencoded, err := utils.EncodeProtobuf(message, nestedMap)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		masked, err := utils.DecodeProtobuf(message, reencoded)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(masked["payload"]).To(gomega.Equal(map[string]interface{}{
+			"id": "PK1", "given_name": "******", "last_name": "******", "country_of_residence": "UK",
+		}))
+	})
+
+	It("should reject a message index outside the schema", func() {
+		schema, err := utils.CompileProtobufSchema(metadataProto, nil)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		_, err = schema.MessageByIndexes([]int{4})
+		gomega.Expect(err).To(gomega.HaveOccurred())
+	})
+})
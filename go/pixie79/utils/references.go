@@ -0,0 +1,258 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// maxReferenceDepth guards against reference cycles between subjects.
+const maxReferenceDepth = 32
+
+// SchemaReference is a schema registry reference from one schema to a type defined under another subject.
+// For Avro the name is the fully qualified name of the referenced type.
+type SchemaReference struct {
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+// ReferenceResolver fetches the schema registered under subject and version along with its own references.
+type ReferenceResolver func(subject string, version int) (string, []SchemaReference, error)
+
+var avroPrimitiveTypes = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true,
+	"float": true, "double": true, "bytes": true, "string": true,
+}
+
+// ResolveAvroReferences recursively fetches every reference of an Avro schema and returns a single
+// self-contained schema that goavro can compile. Each referenced type is inlined at its first use.
+// A subject and version referenced more than once, as in a diamond of references, is fetched once.
+func ResolveAvroReferences(schema string, references []SchemaReference, resolve ReferenceResolver) (string, error) {
+	resolver := &avroReferenceResolver{resolve: resolve, composites: map[referenceKey]string{}}
+	return resolver.compose(schema, references, 0)
+}
+
+// referenceKey identifies a referenced schema by subject and version.
+type referenceKey struct {
+	subject string
+	version int
+}
+
+// avroReferenceResolver holds the composite schemas already resolved within one ResolveAvroReferences call.
+type avroReferenceResolver struct {
+	resolve    ReferenceResolver
+	composites map[referenceKey]string
+}
+
+func (r *avroReferenceResolver) compose(schema string, references []SchemaReference, depth int) (string, error) {
+	if len(references) == 0 {
+		return schema, nil
+	}
+	if depth > maxReferenceDepth {
+		return "", fmt.Errorf("schema references nested deeper than %d, check for a reference cycle", maxReferenceDepth)
+	}
+
+	resolved := make(map[string]string, len(references))
+	for _, reference := range references {
+		key := referenceKey{subject: reference.Subject, version: reference.Version}
+		composite, ok := r.composites[key]
+		if !ok {
+			referenced, nested, err := r.resolve(reference.Subject, reference.Version)
+			if err != nil {
+				return "", fmt.Errorf("unable to resolve reference %s (subject %s version %d): %w", reference.Name, reference.Subject, reference.Version, err)
+			}
+			composite, err = r.compose(referenced, nested, depth+1)
+			if err != nil {
+				return "", err
+			}
+			r.composites[key] = composite
+		}
+		resolved[reference.Name] = composite
+	}
+	return ComposeAvroSchema(schema, resolved)
+}
+
+// ComposeAvroSchema inlines referenced schemas, keyed by type name, into schema.
+//
+// The first use of a referenced name is replaced by its definition and later uses are left as
+// name references. Named types defined more than once, as happens when two references share
+// a common type, are reduced to a single definition.
+func ComposeAvroSchema(schema string, references map[string]string) (string, error) {
+	var root interface{}
+	if err := json.Unmarshal([]byte(schema), &root); err != nil {
+		return "", fmt.Errorf("unable to parse Avro schema: %w", err)
+	}
+
+	composer := &schemaComposer{
+		references: references,
+		defined:    map[string]bool{},
+	}
+	composed, err := composer.walk(root, "")
+	if err != nil {
+		return "", err
+	}
+
+	out, err := json.Marshal(composed)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+type schemaComposer struct {
+	references map[string]string
+	defined    map[string]bool
+}
+
+func (c *schemaComposer) walk(node interface{}, namespace string) (interface{}, error) {
+	switch n := node.(type) {
+	case string:
+		return c.walkName(n, namespace)
+	case []interface{}:
+		branches := make([]interface{}, len(n))
+		for i, branch := range n {
+			walked, err := c.walk(branch, namespace)
+			if err != nil {
+				return nil, err
+			}
+			branches[i] = walked
+		}
+		return branches, nil
+	case map[string]interface{}:
+		return c.walkObject(n, namespace)
+	default:
+		return node, nil
+	}
+}
+
+func (c *schemaComposer) walkName(name, namespace string) (interface{}, error) {
+	if avroPrimitiveTypes[name] {
+		return name, nil
+	}
+	fullName := qualifyAvroName(name, namespace)
+	if c.defined[fullName] {
+		return name, nil
+	}
+
+	referenced, ok := c.references[fullName]
+	if !ok {
+		referenced, ok = c.references[name]
+	}
+	if !ok {
+		// Unknown names are left for goavro to report
+		return name, nil
+	}
+
+	var definition interface{}
+	if err := json.Unmarshal([]byte(referenced), &definition); err != nil {
+		return nil, fmt.Errorf("unable to parse referenced schema %s: %w", name, err)
+	}
+	// Keep the referenced type in its own namespace rather than the one it is inlined into
+	if object, ok := definition.(map[string]interface{}); ok {
+		if _, hasNamespace := object["namespace"]; !hasNamespace && !strings.Contains(fmt.Sprint(object["name"]), ".") {
+			object["namespace"] = ""
+		}
+	}
+	return c.walk(definition, namespace)
+}
+
+func (c *schemaComposer) walkObject(object map[string]interface{}, namespace string) (interface{}, error) {
+	typeName, _ := object["type"].(string)
+	switch typeName {
+	case "record", "error", "enum", "fixed":
+		name, _ := object["name"].(string)
+		typeNamespace := namespace
+		if ns, ok := object["namespace"].(string); ok {
+			typeNamespace = ns
+		}
+		fullName := qualifyAvroName(name, typeNamespace)
+		if c.defined[fullName] {
+			return fullName, nil
+		}
+		c.defined[fullName] = true
+
+		if typeName == "record" || typeName == "error" {
+			// Names inside a record resolve against the record's own namespace
+			if idx := strings.LastIndex(fullName, "."); idx > 0 {
+				typeNamespace = fullName[:idx]
+			}
+			fields, _ := object["fields"].([]interface{})
+			for _, field := range fields {
+				fieldObject, ok := field.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				walked, err := c.walk(fieldObject["type"], typeNamespace)
+				if err != nil {
+					return nil, err
+				}
+				fieldObject["type"] = walked
+			}
+		}
+		return object, nil
+	case "array":
+		items, err := c.walk(object["items"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		object["items"] = items
+		return object, nil
+	case "map":
+		values, err := c.walk(object["values"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		object["values"] = values
+		return object, nil
+	default:
+		if avroPrimitiveTypes[typeName] {
+			return object, nil
+		}
+		walked, err := c.walk(object["type"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		object["type"] = walked
+		return object, nil
+	}
+}
+
+// qualifyAvroName returns the fully qualified form of name within namespace.
+func qualifyAvroName(name, namespace string) string {
+	if strings.Contains(name, ".") || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
+
+// ResolveReferenceSources recursively fetches every schema imported by a Protobuf or JSON Schema and
+// returns their sources keyed by reference name, which is the import path or $ref URL respectively.
+func ResolveReferenceSources(references []SchemaReference, resolve ReferenceResolver) (map[string]string, error) {
+	sources := map[string]string{}
+	var collect func(references []SchemaReference, depth int) error
+	collect = func(references []SchemaReference, depth int) error {
+		if depth > maxReferenceDepth {
+			return fmt.Errorf("schema references nested deeper than %d, check for a reference cycle", maxReferenceDepth)
+		}
+		for _, reference := range references {
+			if _, done := sources[reference.Name]; done {
+				continue
+			}
+			source, nested, err := resolve(reference.Subject, reference.Version)
+			if err != nil {
+				return fmt.Errorf("unable to resolve reference %s (subject %s version %d): %w", reference.Name, reference.Subject, reference.Version, err)
+			}
+			sources[reference.Name] = source
+			if err := collect(nested, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := collect(references, 0); err != nil {
+		return nil, err
+	}
+	return sources, nil
+}
@@ -0,0 +1,102 @@
+package utils_test
+
+import (
+	"errors"
+
+	"pixie79/utils"
+
+	goavro "github.com/linkedin/goavro/v2"
+	. "github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+)
+
+var _ = Describe("Schema references", func() {
+	const (
+		metadataSchema = `{
+			"type": "record", "name": "EventMetadata", "namespace": "com.demo.common",
+			"fields": [
+				{"name": "message_key", "type": "string"},
+				{"name": "source", "type": "Source"}
+			]
+		}`
+		sourceSchema = `{"type": "enum", "name": "Source", "symbols": ["CRM", "WEB"]}`
+		auditSchema  = `{
+			"type": "record", "name": "Audit", "namespace": "com.demo.common",
+			"fields": [{"name": "origin", "type": "Source"}]
+		}`
+		eventSchema = `{
+			"type": "record", "name": "CustomerEvent", "namespace": "com.demo.event.v1",
+			"fields": [
+				{"name": "metadata", "type": "com.demo.common.EventMetadata"},
+				{"name": "previous", "type": ["null", "com.demo.common.EventMetadata"], "default": null},
+				{"name": "audit", "type": "com.demo.common.Audit"}
+			]
+		}`
+	)
+
+	subjects := map[string]struct {
+		schema     string
+		references []utils.SchemaReference
+	}{
+		"metadata-value": {metadataSchema, []utils.SchemaReference{{Name: "Source", Subject: "source-value", Version: 1}}},
+		"source-value":   {sourceSchema, nil},
+		"audit-value":    {auditSchema, []utils.SchemaReference{{Name: "Source", Subject: "source-value", Version: 1}}},
+	}
+
+	resolve := func(subject string, version int) (string, []utils.SchemaReference, error) {
+		s, ok := subjects[subject]
+		if !ok {
+			return "", nil, errors.New("subject not found")
+		}
+		return s.schema, s.references, nil
+	}
+
+	It("should build a composite schema goavro can compile", func() {
+		composite, err := utils.ResolveAvroReferences(eventSchema, []utils.SchemaReference{
+			{Name: "com.demo.common.EventMetadata", Subject: "metadata-value", Version: 1},
+			{Name: "com.demo.common.Audit", Subject: "audit-value", Version: 1},
+		}, resolve)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+		codec, err := goavro.NewCodec(composite)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+		native := map[string]interface{}{
+			"metadata": map[string]interface{}{"message_key": "abc", "source": "CRM"},
+			"previous": nil,
+			"audit":    map[string]interface{}{"origin": "WEB"},
+		}
+		binary, err := codec.BinaryFromNative(nil, native)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		decoded, _, err := codec.NativeFromBinary(binary)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(decoded).To(gomega.Equal(native))
+	})
+
+	It("should fetch a schema referenced from both sides of a diamond once", func() {
+		fetched := map[string]int{}
+		counting := func(subject string, version int) (string, []utils.SchemaReference, error) {
+			fetched[subject]++
+			return resolve(subject, version)
+		}
+		_, err := utils.ResolveAvroReferences(eventSchema, []utils.SchemaReference{
+			{Name: "com.demo.common.EventMetadata", Subject: "metadata-value", Version: 1},
+			{Name: "com.demo.common.Audit", Subject: "audit-value", Version: 1},
+		}, counting)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(fetched).To(gomega.Equal(map[string]int{"metadata-value": 1, "audit-value": 1, "source-value": 1}))
+	})
+
+	It("should return schemas without references unchanged", func() {
+		composite, err := utils.ResolveAvroReferences(sourceSchema, nil, resolve)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(composite).To(gomega.Equal(sourceSchema))
+	})
+
+	It("should report references that cannot be fetched", func() {
+		_, err := utils.ResolveAvroReferences(eventSchema, []utils.SchemaReference{
+			{Name: "com.demo.common.EventMetadata", Subject: "missing-value", Version: 1},
+		}, resolve)
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("missing-value")))
+	})
+})
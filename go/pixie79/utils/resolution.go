@@ -0,0 +1,417 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AvroResolver projects data decoded with a writer schema onto a reader schema following the Avro
+// schema resolution rules: reader fields missing from the writer take their defaults, writer fields
+// missing from the reader are dropped, numeric and string/bytes types are promoted and unions are
+// matched branch by branch.
+type AvroResolver struct {
+	writer *AvroSchema
+	reader *AvroSchema
+}
+
+// NewAvroResolver parses both schemas and checks that data written with the writer schema can be
+// read with the reader schema.
+func NewAvroResolver(writerSchema, readerSchema string) (*AvroResolver, error) {
+	writer, err := ParseAvroSchema(writerSchema)
+	if err != nil {
+		return nil, fmt.Errorf("writer schema: %w", err)
+	}
+	reader, err := ParseAvroSchema(readerSchema)
+	if err != nil {
+		return nil, fmt.Errorf("reader schema: %w", err)
+	}
+	if err := checkResolvable(writer, reader, "", map[[2]*AvroSchema]bool{}); err != nil {
+		return nil, fmt.Errorf("writer schema cannot be resolved against reader schema: %w", err)
+	}
+	return &AvroResolver{writer: writer, reader: reader}, nil
+}
+
+// Resolve converts a goavro native datum written with the writer schema into the reader schema.
+func (r *AvroResolver) Resolve(datum interface{}) (interface{}, error) {
+	return resolveAvro(r.writer, r.reader, datum, "")
+}
+
+// ResolveRecord converts a decoded record written with the writer schema into the reader schema.
+func (r *AvroResolver) ResolveRecord(record map[string]interface{}) (map[string]interface{}, error) {
+	resolved, err := r.Resolve(record)
+	if err != nil {
+		return nil, err
+	}
+	nestedMap, ok := resolved.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("reader schema is not a record")
+	}
+	return nestedMap, nil
+}
+
+func resolveAvro(writer, reader *AvroSchema, datum interface{}, path string) (interface{}, error) {
+	if writer.Type == "union" {
+		branch, value, err := writerBranch(writer, datum)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", displayPath(path), err)
+		}
+		return resolveAvro(branch, reader, value, path)
+	}
+
+	if reader.Type == "union" {
+		branch := readerBranch(writer, reader)
+		if branch == nil {
+			return nil, fmt.Errorf("%s: no branch of the reader union matches %s", displayPath(path), writer.BranchName())
+		}
+		resolved, err := resolveAvro(writer, branch, datum, path)
+		if err != nil || branch.Type == "null" {
+			return nil, err
+		}
+		return map[string]interface{}{branch.BranchName(): resolved}, nil
+	}
+
+	if !sameAvroType(writer, reader) && !canPromote(writer.Type, reader.Type) {
+		return nil, fmt.Errorf("%s: writer type %s does not match reader type %s", displayPath(path), writer.BranchName(), reader.BranchName())
+	}
+
+	switch reader.Type {
+	case "record", "error":
+		record, ok := datum.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: expected a record, got %T", displayPath(path), datum)
+		}
+		resolved := make(map[string]interface{}, len(reader.Fields))
+		for _, readerField := range reader.Fields {
+			fieldPath := joinPath(path, readerField.Name)
+			writerField := writerFieldFor(writer, readerField)
+			if writerField == nil {
+				value, err := AvroDefault(readerField.Type, readerField.Default)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", fieldPath, err)
+				}
+				resolved[readerField.Name] = value
+				continue
+			}
+			value, err := resolveAvro(writerField.Type, readerField.Type, record[writerField.Name], fieldPath)
+			if err != nil {
+				return nil, err
+			}
+			resolved[readerField.Name] = value
+		}
+		return resolved, nil
+	case "enum":
+		symbol, ok := datum.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: expected an enum symbol, got %T", displayPath(path), datum)
+		}
+		for _, known := range reader.Symbols {
+			if known == symbol {
+				return symbol, nil
+			}
+		}
+		if reader.HasEnumDefault {
+			return reader.EnumDefault, nil
+		}
+		return nil, fmt.Errorf("%s: symbol %s is not in reader enum %s", displayPath(path), symbol, reader.Name)
+	case "array":
+		items, ok := datum.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: expected an array, got %T", displayPath(path), datum)
+		}
+		resolved := make([]interface{}, len(items))
+		for i, item := range items {
+			value, err := resolveAvro(writer.Items, reader.Items, item, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			resolved[i] = value
+		}
+		return resolved, nil
+	case "map":
+		values, ok := datum.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: expected a map, got %T", displayPath(path), datum)
+		}
+		resolved := make(map[string]interface{}, len(values))
+		for key, item := range values {
+			value, err := resolveAvro(writer.Values, reader.Values, item, joinPath(path, key))
+			if err != nil {
+				return nil, err
+			}
+			resolved[key] = value
+		}
+		return resolved, nil
+	default:
+		value, err := promoteAvro(writer, reader, datum)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", displayPath(path), err)
+		}
+		return value, nil
+	}
+}
+
+// promoteAvro converts a primitive value to the reader type; int, long and float widen and
+// strings and bytes convert into each other. Dates, times and timestamps, which goavro decodes as
+// time.Time and time.Duration, are promoted as the number they are written as.
+func promoteAvro(writer, reader *AvroSchema, datum interface{}) (interface{}, error) {
+	if writer.Type == reader.Type && writer.Logical == reader.Logical {
+		return datum, nil
+	}
+	datum = avroLogicalNumber(writer, datum)
+	writerType, readerType := writer.Type, reader.Type
+	if writerType == readerType {
+		return datum, nil
+	}
+	switch v := datum.(type) {
+	case int32:
+		switch readerType {
+		case "long":
+			return int64(v), nil
+		case "float":
+			return float32(v), nil
+		case "double":
+			return float64(v), nil
+		}
+	case int64:
+		switch readerType {
+		case "float":
+			return float32(v), nil
+		case "double":
+			return float64(v), nil
+		}
+	case float32:
+		if readerType == "double" {
+			return float64(v), nil
+		}
+	case string:
+		if readerType == "bytes" {
+			return []byte(v), nil
+		}
+	case []byte:
+		if readerType == "string" {
+			return string(v), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot promote %s value %v to %s", writerType, datum, readerType)
+}
+
+// avroLogicalNumber returns the number a date, time or timestamp decoded by goavro is written as:
+// days since the epoch for a date, milliseconds or microseconds for the others.
+func avroLogicalNumber(writer *AvroSchema, datum interface{}) interface{} {
+	switch v := datum.(type) {
+	case time.Time:
+		switch writer.Logical {
+		case "date":
+			days := v.Unix() / secondsPerDay
+			if v.Unix()%secondsPerDay < 0 {
+				days--
+			}
+			return int32(days)
+		case "timestamp-millis":
+			return v.UnixMilli()
+		case "timestamp-micros":
+			return v.UnixMicro()
+		}
+	case time.Duration:
+		switch writer.Logical {
+		case "time-millis":
+			return int32(v.Milliseconds())
+		case "time-micros":
+			return v.Microseconds()
+		}
+	}
+	return datum
+}
+
+// writerBranch returns the writer union branch a goavro native union value was written with.
+func writerBranch(union *AvroSchema, datum interface{}) (*AvroSchema, interface{}, error) {
+	if datum == nil {
+		for _, branch := range union.Branches {
+			if branch.Type == "null" {
+				return branch, nil, nil
+			}
+		}
+		return nil, nil, fmt.Errorf("null value for a union without a null branch")
+	}
+	wrapped, ok := datum.(map[string]interface{})
+	if !ok || len(wrapped) != 1 {
+		return nil, nil, fmt.Errorf("expected a union value, got %T", datum)
+	}
+	for name, value := range wrapped {
+		for _, branch := range union.Branches {
+			if branch.BranchName() == name {
+				return branch, value, nil
+			}
+		}
+		return nil, nil, fmt.Errorf("union value for unknown branch %s", name)
+	}
+	return nil, nil, nil
+}
+
+// readerBranch returns the first reader union branch matching the writer type exactly, falling back
+// to the first branch the writer type can be promoted to.
+func readerBranch(writer, union *AvroSchema) *AvroSchema {
+	for _, branch := range union.Branches {
+		if sameAvroType(writer, branch) {
+			return branch
+		}
+	}
+	for _, branch := range union.Branches {
+		if canPromote(writer.Type, branch.Type) {
+			return branch
+		}
+	}
+	return nil
+}
+
+// sameAvroType reports whether writer and reader are the same kind of type, matching named types by
+// full name, unqualified name or reader alias.
+func sameAvroType(writer, reader *AvroSchema) bool {
+	if writer.Type != reader.Type {
+		return false
+	}
+	if !reader.isNamed() {
+		return true
+	}
+	if writer.Name == reader.Name || unqualifiedName(writer.Name) == unqualifiedName(reader.Name) {
+		return true
+	}
+	for _, alias := range reader.Aliases {
+		if alias == writer.Name {
+			return true
+		}
+	}
+	return false
+}
+
+func canPromote(writerType, readerType string) bool {
+	switch writerType {
+	case "int":
+		return readerType == "long" || readerType == "float" || readerType == "double"
+	case "long":
+		return readerType == "float" || readerType == "double"
+	case "float":
+		return readerType == "double"
+	case "string":
+		return readerType == "bytes"
+	case "bytes":
+		return readerType == "string"
+	}
+	return false
+}
+
+func writerFieldFor(writer *AvroSchema, readerField *AvroField) *AvroField {
+	for _, field := range writer.Fields {
+		if field.Name == readerField.Name {
+			return field
+		}
+	}
+	for _, alias := range readerField.Aliases {
+		for _, field := range writer.Fields {
+			if field.Name == alias {
+				return field
+			}
+		}
+	}
+	return nil
+}
+
+// checkResolvable verifies the writer schema resolves against the reader schema without needing any data.
+func checkResolvable(writer, reader *AvroSchema, path string, seen map[[2]*AvroSchema]bool) error {
+	pair := [2]*AvroSchema{writer, reader}
+	if seen[pair] {
+		return nil
+	}
+	seen[pair] = true
+
+	if writer.Type == "union" {
+		// Only the branch a value was written with is resolved, so one readable branch is enough;
+		// values written with any other branch are reported as they are resolved
+		var firstErr error
+		for _, branch := range writer.Branches {
+			err := checkResolvable(branch, reader, path, seen)
+			if err == nil {
+				return nil
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		return firstErr
+	}
+	if reader.Type == "union" {
+		branch := readerBranch(writer, reader)
+		if branch == nil {
+			return fmt.Errorf("%s: no branch of the reader union matches %s", displayPath(path), writer.BranchName())
+		}
+		return checkResolvable(writer, branch, path, seen)
+	}
+
+	if !sameAvroType(writer, reader) {
+		if canPromote(writer.Type, reader.Type) {
+			return nil
+		}
+		return fmt.Errorf("%s: writer type %s does not match reader type %s", displayPath(path), writer.BranchName(), reader.BranchName())
+	}
+
+	switch reader.Type {
+	case "record", "error":
+		for _, readerField := range reader.Fields {
+			fieldPath := joinPath(path, readerField.Name)
+			writerField := writerFieldFor(writer, readerField)
+			if writerField == nil {
+				if !readerField.HasDefault {
+					return fmt.Errorf("%s: field is missing from the writer schema and has no default", fieldPath)
+				}
+				if _, err := AvroDefault(readerField.Type, readerField.Default); err != nil {
+					return fmt.Errorf("%s: invalid default: %w", fieldPath, err)
+				}
+				continue
+			}
+			if err := checkResolvable(writerField.Type, readerField.Type, fieldPath, seen); err != nil {
+				return err
+			}
+		}
+	case "enum":
+		if reader.HasEnumDefault {
+			return nil
+		}
+		for _, symbol := range writer.Symbols {
+			found := false
+			for _, known := range reader.Symbols {
+				found = found || known == symbol
+			}
+			if !found {
+				return fmt.Errorf("%s: symbol %s is not in reader enum %s and it has no default", displayPath(path), symbol, reader.Name)
+			}
+		}
+	case "fixed":
+		if writer.Size != reader.Size {
+			return fmt.Errorf("%s: fixed size %d does not match reader size %d", displayPath(path), writer.Size, reader.Size)
+		}
+	case "array":
+		return checkResolvable(writer.Items, reader.Items, path+"[]", seen)
+	case "map":
+		return checkResolvable(writer.Values, reader.Values, joinPath(path, "*"), seen)
+	}
+	return nil
+}
+
+func unqualifiedName(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "record"
+	}
+	return path
+}
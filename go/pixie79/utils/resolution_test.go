@@ -0,0 +1,130 @@
+package utils_test
+
+import (
+	"time"
+
+	"pixie79/utils"
+
+	goavro "github.com/linkedin/goavro/v2"
+	. "github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+)
+
+var _ = Describe("Avro schema resolution", func() {
+	const (
+		writerSchema = `{
+			"type": "record", "name": "Customer", "namespace": "com.demo.v1",
+			"fields": [
+				{"name": "id", "type": "string"},
+				{"name": "age", "type": "int"},
+				{"name": "nickname", "type": ["null", "string"], "default": null},
+				{"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["ACTIVE", "SUSPENDED", "CLOSED"]}},
+				{"name": "legacy_code", "type": "string"},
+				{"name": "scores", "type": {"type": "array", "items": "float"}}
+			]
+		}`
+		readerSchema = `{
+			"type": "record", "name": "Customer", "namespace": "com.demo.v2",
+			"fields": [
+				{"name": "id", "type": "string"},
+				{"name": "age", "type": ["null", "long"], "default": null},
+				{"name": "nickname", "type": "string", "default": ""},
+				{"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["ACTIVE", "UNKNOWN"], "default": "UNKNOWN"}},
+				{"name": "scores", "type": {"type": "array", "items": "double"}},
+				{"name": "region", "type": "string", "default": "eu"},
+				{"name": "tags", "type": {"type": "map", "values": "string"}, "default": {"source": "legacy"}}
+			]
+		}`
+	)
+
+	written := map[string]interface{}{
+		"id":          "c-1",
+		"age":         int32(42),
+		"nickname":    map[string]interface{}{"string": "JJ"},
+		"status":      "SUSPENDED",
+		"legacy_code": "X1",
+		"scores":      []interface{}{float32(1.5)},
+	}
+
+	It("should project a record onto an evolved reader schema", func() {
+		resolver, err := utils.NewAvroResolver(writerSchema, readerSchema)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+		resolved, err := resolver.ResolveRecord(written)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(resolved).To(gomega.Equal(map[string]interface{}{
+			"id":       "c-1",
+			"age":      map[string]interface{}{"long": int64(42)},
+			"nickname": "JJ",
+			"status":   "UNKNOWN",
+			"scores":   []interface{}{float64(1.5)},
+			"region":   "eu",
+			"tags":     map[string]interface{}{"source": "legacy"},
+		}))
+
+		codec, err := goavro.NewCodec(readerSchema)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		_, err = codec.BinaryFromNative(nil, resolved)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+	})
+
+	It("should report a null written to a field the reader does not allow to be null", func() {
+		resolver, err := utils.NewAvroResolver(writerSchema, readerSchema)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+		record := map[string]interface{}{}
+		for k, v := range written {
+			record[k] = v
+		}
+		record["nickname"] = nil
+		_, err = resolver.ResolveRecord(record)
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("nickname")))
+	})
+
+	It("should reject reader fields without a default that the writer does not have", func() {
+		_, err := utils.NewAvroResolver(writerSchema, `{
+			"type": "record", "name": "Customer",
+			"fields": [{"name": "email", "type": "string"}]
+		}`)
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("email")))
+	})
+
+	It("should promote dates, times and timestamps as the numbers they are written as", func() {
+		resolver, err := utils.NewAvroResolver(`{
+			"type": "record", "name": "Customer",
+			"fields": [
+				{"name": "joined", "type": {"type": "int", "logicalType": "date"}},
+				{"name": "seen", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+				{"name": "opens", "type": {"type": "int", "logicalType": "time-millis"}}
+			]
+		}`, `{
+			"type": "record", "name": "Customer",
+			"fields": [
+				{"name": "joined", "type": "long"},
+				{"name": "seen", "type": "double"},
+				{"name": "opens", "type": ["null", "long"]}
+			]
+		}`)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+		resolved, err := resolver.ResolveRecord(map[string]interface{}{
+			"joined": time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC),
+			"seen":   time.UnixMilli(1709251200123).UTC(),
+			"opens":  9 * time.Hour,
+		})
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(resolved).To(gomega.Equal(map[string]interface{}{
+			"joined": int64(19783),
+			"seen":   float64(1709251200123),
+			"opens":  map[string]interface{}{"long": int64(32400000)},
+		}))
+	})
+
+	It("should reject types that cannot be promoted", func() {
+		_, err := utils.NewAvroResolver(writerSchema, `{
+			"type": "record", "name": "Customer",
+			"fields": [{"name": "age", "type": "string"}]
+		}`)
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("age")))
+	})
+})
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+// RegionRouter maps a record to a region-specific output topic based on a field such as
+// payload.country_of_residence. Records with a null or unmapped value are sent to the quarantine topic.
+type RegionRouter struct {
+	Field           string            // Dotted path to the field holding the country
+	Regions         map[string]string // Upper-cased country to region, e.g. "UK" -> "eu"
+	TopicPrefix     string            // Regional topics are named <TopicPrefix>-<region>
+	QuarantineTopic string            // Destination for unknown or null countries
+}
+
+// NewRegionRouter builds a RegionRouter from a JSON country-to-region map such as {"UK": "eu", "USA": "us"}.
+//
+// If quarantineTopic is empty it defaults to <topicPrefix>-quarantine.
+func NewRegionRouter(field, regionMapJSON, topicPrefix, quarantineTopic string) (*RegionRouter, error) {
+	var regionMap map[string]string
+	if err := json.Unmarshal([]byte(regionMapJSON), &regionMap); err != nil {
+		slog.Error("Error unmarshalling region map", "Error", err)
+		return nil, err
+	}
+	if len(regionMap) == 0 {
+		return nil, errors.New("region map must contain at least one country")
+	}
+	if topicPrefix == "" {
+		return nil, errors.New("topic prefix is required")
+	}
+
+	if field == "" {
+		field = DefaultJurisdictionField
+	}
+	if quarantineTopic == "" {
+		quarantineTopic = topicPrefix + "-quarantine"
+	}
+
+	regions := make(map[string]string, len(regionMap))
+	for country, region := range regionMap {
+		if region == "" {
+			return nil, errors.New("region for country " + country + " must not be empty")
+		}
+		regions[strings.ToUpper(country)] = strings.ToLower(region)
+	}
+
+	return &RegionRouter{
+		Field:           field,
+		Regions:         regions,
+		TopicPrefix:     topicPrefix,
+		QuarantineTopic: quarantineTopic,
+	}, nil
+}
+
+// TopicFor returns the output topic for the record and whether it was quarantined.
+func (r *RegionRouter) TopicFor(record map[string]interface{}) (string, bool) {
+	country, ok := GetStringField(record, r.Field)
+	if !ok || country == "" {
+		return r.QuarantineTopic, true
+	}
+	region, ok := r.Regions[strings.ToUpper(country)]
+	if !ok {
+		return r.QuarantineTopic, true
+	}
+	return r.regionTopic(region), false
+}
+
+// Topics returns every topic the router may write to, which must all be output topics of the transform.
+func (r *RegionRouter) Topics() []string {
+	seen := map[string]bool{r.QuarantineTopic: true}
+	topics := []string{}
+	for _, region := range r.Regions {
+		topic := r.regionTopic(region)
+		if !seen[topic] {
+			seen[topic] = true
+			topics = append(topics, topic)
+		}
+	}
+	sort.Strings(topics)
+	return append(topics, r.QuarantineTopic)
+}
+
+func (r *RegionRouter) regionTopic(region string) string {
+	return r.TopicPrefix + "-" + region
+}
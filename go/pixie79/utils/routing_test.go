@@ -0,0 +1,52 @@
+package utils_test
+
+import (
+	"pixie79/utils"
+
+	. "github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+)
+
+var _ = Describe("RegionRouter", func() {
+	var (
+		router *utils.RegionRouter
+		err    error
+	)
+
+	newRecord := func(country interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"payload": map[string]interface{}{"country_of_residence": country},
+		}
+	}
+
+	BeforeEach(func() {
+		router, err = utils.NewRegionRouter("", `{"uk": "EU", "Germany": "eu", "USA": "us"}`, "output-demo", "")
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+	})
+
+	It("should route known countries to their regional topic", func() {
+		topic, quarantined := router.TopicFor(newRecord(map[string]interface{}{"string": "UK"}))
+		gomega.Expect(topic).To(gomega.Equal("output-demo-eu"))
+		gomega.Expect(quarantined).To(gomega.BeFalse())
+
+		topic, _ = router.TopicFor(newRecord(map[string]interface{}{"string": "usa"}))
+		gomega.Expect(topic).To(gomega.Equal("output-demo-us"))
+	})
+
+	It("should quarantine unknown and null countries", func() {
+		for _, country := range []interface{}{nil, map[string]interface{}{"string": "Australia"}} {
+			topic, quarantined := router.TopicFor(newRecord(country))
+			gomega.Expect(topic).To(gomega.Equal("output-demo-quarantine"))
+			gomega.Expect(quarantined).To(gomega.BeTrue())
+		}
+	})
+
+	It("should list every output topic once", func() {
+		gomega.Expect(router.Topics()).To(gomega.Equal([]string{"output-demo-eu", "output-demo-us", "output-demo-quarantine"}))
+	})
+
+	It("should reject an empty region map", func() {
+		_, err = utils.NewRegionRouter("", `{}`, "output-demo", "")
+		gomega.Expect(err).To(gomega.HaveOccurred())
+	})
+})
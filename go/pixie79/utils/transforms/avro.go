@@ -6,71 +6,104 @@ import (
 	"log/slog"
 	"os"
 	pUtils "pixie79/utils"
-	"strconv"
 
 	avro "github.com/linkedin/goavro/v2"
 	transform "github.com/redpanda-data/redpanda/src/transform-sdk/go/transform"
-	sr "github.com/redpanda-data/redpanda/src/transform-sdk/go/transform/sr"
 )
 
+// DecodeAvroRawEvent decodes the value of a schema registry encoded record into a nested map.
+//
+// The magic byte and schema ID are read directly from the record value and the remaining
+// bytes are decoded as binary Avro with the source codec, which is compiled once per schema ID.
 func DecodeAvroRawEvent(e transform.WriteEvent) (map[string]interface{}, error) {
-	rawEvent, err := json.Marshal(e.Record().Value)
+	// Extract the source schema ID from the event
+	sourceSchemaID, payload, err := pUtils.DecodeBuffer(e.Record().Value)
 	if err != nil {
-		slog.Error("Unable to marshal raw event", "Error", e.Record().Value)
+		slog.Error("Unable to read schema registry header", "Error", err)
 		return nil, err
 	}
 
-	// Sensitive Debug output
-	// slog.Debug("Raw Event", "Event", rawEvent)
+	sourceCodec, err := codecCache.Get(sourceSchemaID)
+	if err != nil {
+		slog.Error("Error retrieving source schema", "Error", err)
+		return nil, err
+	}
 
-	// Extract the source schema ID from the event
-	sourceSchemaID, err := sr.ExtractID(e.Record().Value)
+	nestedMap, err := pUtils.DecodeAvroWithCodec(sourceCodec, payload)
 	if err != nil {
-		slog.Error("SCHEMA_ID not an integer", "Error", strconv.Itoa(sourceSchemaID))
+		slog.Error("Unable to decode Avro event", "Error", err)
 		return nil, err
 	}
+	return nestedMap, nil
+}
 
-	sourceSchema, err := getSchema(fmt.Sprintf("%d", sourceSchemaID))
+// resolveAvro projects a record written with the source schema onto the destination schema.
+func (d *Destination) resolveAvro(sourceSchemaID int, nestedMap map[string]interface{}) (map[string]interface{}, error) {
+	resolved, err := d.resolveAvroDatum(sourceSchemaID, nestedMap)
 	if err != nil {
-		slog.Error("Error retrieving source schema", "Error", err)
 		return nil, err
 	}
-	nestedMap := pUtils.DecodeAvro(sourceSchema, rawEvent)
-	if nestedMap == nil {
-		slog.Error("Unable to decode Avro event")
+	resolvedMap, ok := resolved.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("destination schema %d is not a record", d.SchemaID)
+	}
+	return resolvedMap, nil
+}
+
+// resolveAvroDatum projects a value written with the source schema onto the destination schema.
+// The resolver for each source schema is built, and its compatibility checked, on first use.
+func (d *Destination) resolveAvroDatum(sourceSchemaID int, datum interface{}) (interface{}, error) {
+	if sourceSchemaID == d.SchemaID {
+		return datum, nil
+	}
+
+	resolver, ok := d.avroResolvers[sourceSchemaID]
+	if !ok {
+		sourceCodec, err := codecCache.Get(sourceSchemaID)
+		if err != nil {
+			return nil, err
+		}
+		if sourceCodec.CanonicalSchema() != d.AvroCodec.CanonicalSchema() {
+			resolver, err = pUtils.NewAvroResolver(sourceCodec.Schema(), d.AvroCodec.Schema())
+			if err != nil {
+				slog.Error("Source schema is not compatible with the destination schema", "sourceSchemaID", sourceSchemaID, "destinationSchemaID", d.SchemaID, "Error", err)
+				return nil, err
+			}
+		}
+		if d.avroResolvers == nil {
+			d.avroResolvers = map[int]*pUtils.AvroResolver{}
+		}
+		d.avroResolvers[sourceSchemaID] = resolver
+	}
+	if resolver == nil {
+		return datum, nil
+	}
+
+	resolved, err := resolver.Resolve(datum)
+	if err != nil {
+		slog.Error("Unable to resolve record against the destination schema", "sourceSchemaID", sourceSchemaID, "Error", err)
 		return nil, err
 	}
-	return nestedMap, nil
+	return resolved, nil
 }
 
 func FetchAvroDestinationSchema() (*avro.Codec, []byte, error) {
 	var (
-		destinationSchemaID    string
 		destinationSchemaIDInt int
 		destinationCodec       *avro.Codec
 		err                    error
 	)
 
-	destinationSchemaID = os.Getenv("DESTINATION_SCHEMA_ID")
-	if destinationSchemaID == "" {
-		panic("DESTINATION_SCHEMA_ID environment variable is required")
-	}
-
-	destinationSchemaIDInt, err = strconv.Atoi(destinationSchemaID)
+	destinationSchemaIDInt, err = destinationSchemaID()
 	if err != nil {
-		panic(fmt.Sprintf("DESTINATION_SCHEMA_ID not an integer: %s", destinationSchemaID))
+		panic(err.Error())
 	}
 
-	destinationSchema, err := getSchema(destinationSchemaID)
+	destinationCodec, err = codecCache.Get(destinationSchemaIDInt)
 	if err != nil {
 		panic(fmt.Sprintf("Error retrieving destination schema: %v\n", err))
 	}
 
-	destinationCodec, err = avro.NewCodec(destinationSchema)
-	if err != nil {
-		panic(fmt.Sprintf("Error creating Avro codec: %v\n", err))
-	}
-
 	hdr := pUtils.EncodeBuffer(destinationSchemaIDInt)
 
 	return destinationCodec, hdr, nil
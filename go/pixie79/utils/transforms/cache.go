@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"container/list"
+	"log/slog"
+	"sync"
+
+	avro "github.com/linkedin/goavro/v2"
+)
+
+// defaultCodecCacheSize bounds the shared codec cache; each distinct source schema version uses one entry.
+const defaultCodecCacheSize = 64
+
+// statsLogInterval is the number of lookups between cache statistics log lines.
+const statsLogInterval = 1000
+
+// SchemaLookup fetches the schema definition registered under a schema ID.
+type SchemaLookup func(id int) (string, error)
+
+// CodecCacheStats reports how effective the codec cache has been.
+type CodecCacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+}
+
+// CodecCache is a bounded, least recently used cache of compiled Avro codecs keyed by schema ID,
+// so each schema is fetched from the registry and compiled once per transform instance.
+type CodecCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[int]*list.Element
+	order      *list.List
+	lookup     SchemaLookup
+	stats      CodecCacheStats
+}
+
+type codecCacheEntry struct {
+	id    int
+	codec *avro.Codec
+}
+
+var codecCache = NewCodecCache(defaultCodecCacheSize, getSchema)
+
+// NewCodecCache creates a codec cache holding at most maxEntries codecs, resolving misses with lookup.
+func NewCodecCache(maxEntries int, lookup SchemaLookup) *CodecCache {
+	if maxEntries < 1 {
+		maxEntries = 1
+	}
+	return &CodecCache{
+		maxEntries: maxEntries,
+		entries:    make(map[int]*list.Element),
+		order:      list.New(),
+		lookup:     lookup,
+	}
+}
+
+// SetCodecCacheSize replaces the shared codec cache with one bounded to size entries.
+func SetCodecCacheSize(size int) {
+	codecCache = NewCodecCache(size, getSchema)
+}
+
+// GetCodecCacheStats returns the statistics of the shared codec cache.
+func GetCodecCacheStats() CodecCacheStats {
+	return codecCache.Stats()
+}
+
+// Get returns the codec for the schema ID, fetching and compiling the schema on a miss.
+func (c *CodecCache) Get(id int) (*avro.Codec, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	defer c.logStats()
+
+	if element, ok := c.entries[id]; ok {
+		c.stats.Hits++
+		c.order.MoveToFront(element)
+		return element.Value.(*codecCacheEntry).codec, nil
+	}
+	c.stats.Misses++
+
+	schema, err := c.lookup(id)
+	if err != nil {
+		slog.Error("Error retrieving schema", "schemaID", id, "Error", err)
+		return nil, err
+	}
+	codec, err := avro.NewCodec(schema)
+	if err != nil {
+		slog.Error("Error creating Avro codec", "schemaID", id, "Error", err)
+		return nil, err
+	}
+
+	c.entries[id] = c.order.PushFront(&codecCacheEntry{id: id, codec: codec})
+	if c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*codecCacheEntry).id)
+		c.stats.Evictions++
+	}
+	slog.Debug("Cached Avro codec", "schemaID", id, "entries", c.order.Len())
+	return codec, nil
+}
+
+// Stats returns a snapshot of the cache statistics.
+func (c *CodecCache) Stats() CodecCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = c.order.Len()
+	return stats
+}
+
+// logStats periodically reports the hit and miss counts; the caller must hold the lock.
+func (c *CodecCache) logStats() {
+	if (c.stats.Hits+c.stats.Misses)%statsLogInterval != 0 {
+		return
+	}
+	slog.Info("Codec cache statistics", "hits", c.stats.Hits, "misses", c.stats.Misses, "evictions", c.stats.Evictions, "entries", c.order.Len())
+}
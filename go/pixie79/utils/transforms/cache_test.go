@@ -0,0 +1,62 @@
+package utils_test
+
+import (
+	"errors"
+	"fmt"
+
+	pTransforms "pixie79/utils/transforms"
+
+	. "github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+)
+
+var _ = Describe("CodecCache", func() {
+	var (
+		lookups map[int]int
+		cache   *pTransforms.CodecCache
+	)
+
+	schemaFor := func(id int) string {
+		return fmt.Sprintf(`{"type": "record", "name": "Schema%d", "fields": [{"name": "id", "type": "string"}]}`, id)
+	}
+
+	BeforeEach(func() {
+		lookups = map[int]int{}
+		cache = pTransforms.NewCodecCache(2, func(id int) (string, error) {
+			lookups[id]++
+			if id == 0 {
+				return "", errors.New("schema not found")
+			}
+			return schemaFor(id), nil
+		})
+	})
+
+	It("should fetch each schema once", func() {
+		for i := 0; i < 3; i++ {
+			codec, err := cache.Get(1)
+			gomega.Expect(err).NotTo(gomega.HaveOccurred())
+			gomega.Expect(codec).NotTo(gomega.BeNil())
+		}
+		gomega.Expect(lookups[1]).To(gomega.Equal(1))
+		gomega.Expect(cache.Stats()).To(gomega.Equal(pTransforms.CodecCacheStats{Hits: 2, Misses: 1, Entries: 1}))
+	})
+
+	It("should evict the least recently used codec", func() {
+		for _, id := range []int{1, 2, 1, 3, 1, 2} {
+			_, err := cache.Get(id)
+			gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		}
+		gomega.Expect(lookups).To(gomega.Equal(map[int]int{1: 1, 2: 2, 3: 1}))
+		gomega.Expect(cache.Stats().Evictions).To(gomega.Equal(uint64(2)))
+		gomega.Expect(cache.Stats().Entries).To(gomega.Equal(2))
+	})
+
+	It("should not cache failed lookups", func() {
+		_, err := cache.Get(0)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		_, err = cache.Get(0)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		gomega.Expect(lookups[0]).To(gomega.Equal(2))
+		gomega.Expect(cache.Stats().Entries).To(gomega.Equal(0))
+	})
+})
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"log/slog"
+	pUtils "pixie79/utils"
+	"strconv"
+
+	transform "github.com/redpanda-data/redpanda/src/transform-sdk/go/transform"
+)
+
+// deadLetterStatsInterval is how many dead-lettered records pass between statistics log lines.
+const deadLetterStatsInterval = 100
+
+// DeadLetterQueue writes records the transform could not process to a dead-letter topic, unchanged
+// apart from headers describing the failure, so processing continues with the next record.
+type DeadLetterQueue struct {
+	Topic         string
+	TransformName string
+	SourceTopic   string
+	stats         DeadLetterStats
+}
+
+// DeadLetterStats counts the records written to the dead-letter topic.
+type DeadLetterStats struct {
+	Total   int64
+	ByStage map[string]int64
+}
+
+// NewDeadLetterQueue creates a dead-letter queue writing to topic.
+func NewDeadLetterQueue(topic, transformName, sourceTopic string) *DeadLetterQueue {
+	return &DeadLetterQueue{
+		Topic:         topic,
+		TransformName: transformName,
+		SourceTopic:   sourceTopic,
+		stats:         DeadLetterStats{ByStage: map[string]int64{}},
+	}
+}
+
+// Record returns the original record with the dead-letter headers for the failed stage appended.
+// Dead-letter headers from an earlier failure are replaced.
+func (q *DeadLetterQueue) Record(original transform.Record, stage string, cause error) transform.Record {
+	headers := make([]transform.RecordHeader, 0, len(original.Headers)+5)
+	for _, header := range original.Headers {
+		if !pUtils.IsDeadLetterHeader(string(header.Key)) {
+			headers = append(headers, header)
+		}
+	}
+
+	headers = append(headers,
+		transform.RecordHeader{Key: []byte(pUtils.DeadLetterStageHeader), Value: []byte(stage)},
+		transform.RecordHeader{Key: []byte(pUtils.DeadLetterErrorHeader), Value: []byte(cause.Error())},
+		transform.RecordHeader{Key: []byte(pUtils.DeadLetterTransformHeader), Value: []byte(q.TransformName)},
+	)
+	if q.SourceTopic != "" {
+		headers = append(headers, transform.RecordHeader{Key: []byte(pUtils.DeadLetterSourceTopicHeader), Value: []byte(q.SourceTopic)})
+	}
+	if schemaID, _, err := pUtils.DecodeBuffer(original.Value); err == nil {
+		headers = append(headers, transform.RecordHeader{Key: []byte(pUtils.DeadLetterSourceSchemaIDHeader), Value: []byte(strconv.Itoa(schemaID))})
+	}
+
+	return transform.Record{
+		Key:     original.Key,
+		Value:   original.Value,
+		Headers: headers,
+	}
+}
+
+// Write dead-letters the record of the event that failed at stage.
+func (q *DeadLetterQueue) Write(w transform.RecordWriter, e transform.WriteEvent, stage string, cause error) error {
+	if err := w.Write(q.Record(e.Record(), stage, cause), transform.ToTopic(q.Topic)); err != nil {
+		slog.Error("Error writing record to dead-letter topic", "topic", q.Topic, "Error", err)
+		return err
+	}
+
+	q.stats.Total++
+	q.stats.ByStage[stage]++
+	slog.Warn("Record written to dead-letter topic", "topic", q.Topic, "stage", stage, "Error", cause)
+	if q.stats.Total%deadLetterStatsInterval == 0 {
+		slog.Info("Dead-letter statistics", "total", q.stats.Total, "byStage", q.stats.ByStage)
+	}
+	return nil
+}
+
+// Stats returns a copy of the dead-letter counters.
+func (q *DeadLetterQueue) Stats() DeadLetterStats {
+	byStage := make(map[string]int64, len(q.stats.ByStage))
+	for stage, count := range q.stats.ByStage {
+		byStage[stage] = count
+	}
+	return DeadLetterStats{Total: q.stats.Total, ByStage: byStage}
+}
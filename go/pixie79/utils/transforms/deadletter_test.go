@@ -0,0 +1,78 @@
+package utils_test
+
+import (
+	"errors"
+
+	pUtils "pixie79/utils"
+	pTransforms "pixie79/utils/transforms"
+
+	. "github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+	transform "github.com/redpanda-data/redpanda/src/transform-sdk/go/transform"
+)
+
+type writeEvent struct {
+	record transform.Record
+}
+
+func (e writeEvent) Record() transform.Record {
+	return e.record
+}
+
+type recordWriter struct {
+	records []transform.Record
+}
+
+func (w *recordWriter) Write(record transform.Record, _ ...transform.WriteOpt) error {
+	w.records = append(w.records, record)
+	return nil
+}
+
+var _ = Describe("DeadLetterQueue", func() {
+	headerValue := func(record transform.Record, key string) string {
+		for _, header := range record.Headers {
+			if string(header.Key) == key {
+				return string(header.Value)
+			}
+		}
+		return ""
+	}
+
+	It("should write the original record with headers describing the failure", func() {
+		queue := pTransforms.NewDeadLetterQueue("demo-dlq", "demo", "demo")
+		original := transform.Record{
+			Key:     []byte("eventKey"),
+			Value:   append(pUtils.EncodeBuffer(42), 0x02, 0x03),
+			Headers: []transform.RecordHeader{{Key: []byte("trace"), Value: []byte("abc")}},
+		}
+
+		writer := &recordWriter{}
+		err := queue.Write(writer, writeEvent{original}, pUtils.DeadLetterStageDecode, errors.New("bad payload"))
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(writer.records).To(gomega.HaveLen(1))
+
+		written := writer.records[0]
+		gomega.Expect(written.Key).To(gomega.Equal(original.Key))
+		gomega.Expect(written.Value).To(gomega.Equal(original.Value))
+		gomega.Expect(headerValue(written, "trace")).To(gomega.Equal("abc"))
+		gomega.Expect(headerValue(written, pUtils.DeadLetterStageHeader)).To(gomega.Equal("decode"))
+		gomega.Expect(headerValue(written, pUtils.DeadLetterErrorHeader)).To(gomega.Equal("bad payload"))
+		gomega.Expect(headerValue(written, pUtils.DeadLetterSourceSchemaIDHeader)).To(gomega.Equal("42"))
+		gomega.Expect(headerValue(written, pUtils.DeadLetterSourceTopicHeader)).To(gomega.Equal("demo"))
+		gomega.Expect(headerValue(written, pUtils.DeadLetterTransformHeader)).To(gomega.Equal("demo"))
+	})
+
+	It("should replace the headers of an earlier failure and count failures by stage", func() {
+		queue := pTransforms.NewDeadLetterQueue("demo-dlq", "demo", "")
+		original := queue.Record(transform.Record{Value: []byte("not wire format")}, pUtils.DeadLetterStageDecode, errors.New("first"))
+
+		writer := &recordWriter{}
+		gomega.Expect(queue.Write(writer, writeEvent{original}, pUtils.DeadLetterStageEncode, errors.New("second"))).To(gomega.Succeed())
+		gomega.Expect(writer.records[0].Headers).To(gomega.HaveLen(3))
+		gomega.Expect(headerValue(writer.records[0], pUtils.DeadLetterErrorHeader)).To(gomega.Equal("second"))
+
+		stats := queue.Stats()
+		gomega.Expect(stats.Total).To(gomega.Equal(int64(1)))
+		gomega.Expect(stats.ByStage).To(gomega.Equal(map[string]int64{pUtils.DeadLetterStageEncode: 1}))
+	})
+})
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"log/slog"
+	pUtils "pixie79/utils"
+
+	transform "github.com/redpanda-data/redpanda/src/transform-sdk/go/transform"
+)
+
+// jsonSchemas holds compiled JSON Schemas by schema ID so each one is compiled once per transform instance.
+var jsonSchemas = map[int]*pUtils.JSONSchema{}
+
+// getJSONSchema returns the compiled JSON Schema registered under the ID.
+func getJSONSchema(id int) (*pUtils.JSONSchema, error) {
+	if schema, ok := jsonSchemas[id]; ok {
+		return schema, nil
+	}
+
+	registered, err := lookupSchema(id)
+	if err != nil {
+		return nil, err
+	}
+	references, err := pUtils.ResolveReferenceSources(toSchemaReferences(registered.References), resolveReference)
+	if err != nil {
+		slog.Error("Error resolving JSON Schema references", "schemaID", id, "Error", err)
+		return nil, err
+	}
+	schema, err := pUtils.CompileJSONSchema(registered.Schema, references)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonSchemas[id] = schema
+	return schema, nil
+}
+
+// DecodeJSONRawEvent validates a JSON Schema wire-format record value against its registered schema and
+// returns it as a nested map.
+func DecodeJSONRawEvent(e transform.WriteEvent) (map[string]interface{}, error) {
+	sourceSchemaID, payload, err := pUtils.DecodeBuffer(e.Record().Value)
+	if err != nil {
+		slog.Error("Unable to read schema registry header", "Error", err)
+		return nil, err
+	}
+
+	schema, err := getJSONSchema(sourceSchemaID)
+	if err != nil {
+		slog.Error("Error retrieving source schema", "Error", err)
+		return nil, err
+	}
+
+	nestedMap, err := pUtils.DecodeJSONSchema(schema, payload)
+	if err != nil {
+		slog.Error("Unable to decode JSON event", "Error", err)
+		return nil, err
+	}
+	return nestedMap, nil
+}
+
+// EncodeJSONRecord validates the nested map against the JSON Schema and prefixes it with hdr.
+func EncodeJSONRecord(nestedMap map[string]interface{}, schema *pUtils.JSONSchema, hdr []byte, key []byte, headers []transform.RecordHeader) (transform.Record, error) {
+	encoded, err := pUtils.EncodeJSONSchema(schema, nestedMap)
+	if err != nil {
+		slog.Error("Error encoding JSON", "Error", err)
+		return transform.Record{}, err
+	}
+
+	record := transform.Record{
+		Key:     key,
+		Value:   append(append([]byte{}, hdr...), encoded...),
+		Headers: headers,
+	}
+
+	return record, nil
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	pUtils "pixie79/utils"
+
+	sr "github.com/redpanda-data/redpanda/src/transform-sdk/go/transform/sr"
+)
+
+// FetchKeyDestination resolves the Avro schema for record keys from DESTINATION_KEY_SUBJECT and
+// DESTINATION_KEY_SCHEMA_VERSION, or from DESTINATION_KEY_SCHEMA_ID. Without either, the key subject of
+// the first output topic is used when it is registered. It returns nil when keys are not schema encoded.
+func FetchKeyDestination() (*Destination, error) {
+	schemaID, ok, err := keySchemaID(os.Getenv("REDPANDA_OUTPUT_TOPIC_0"))
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	keyDestination, err := newDestination(schemaID, "")
+	if err != nil {
+		return nil, err
+	}
+	if keyDestination.Type != sr.TypeAvro {
+		return nil, fmt.Errorf("key schema %d is not an Avro schema", schemaID)
+	}
+	slog.Info("Record keys are Avro encoded", "schemaID", schemaID)
+	return keyDestination, nil
+}
+
+// DecodeKey decodes a schema registry encoded Avro key and projects it onto the key destination schema.
+// It returns false for keys without a schema registry header, which are copied unchanged.
+func (d *Destination) DecodeKey(key []byte) (interface{}, bool, error) {
+	sourceSchemaID, payload, err := pUtils.DecodeBuffer(key)
+	if err != nil {
+		return nil, false, nil
+	}
+
+	sourceCodec, err := codecCache.Get(sourceSchemaID)
+	if err != nil {
+		slog.Error("Error retrieving key schema", "Error", err)
+		return nil, false, err
+	}
+	native, _, err := sourceCodec.NativeFromBinary(payload)
+	if err != nil {
+		slog.Error("Unable to decode Avro key", "Error", err)
+		return nil, false, err
+	}
+
+	native, err = d.resolveAvroDatum(sourceSchemaID, native)
+	if err != nil {
+		return nil, false, err
+	}
+	return native, true, nil
+}
+
+// EncodeKey encodes a key with the key destination schema.
+func (d *Destination) EncodeKey(key interface{}) ([]byte, error) {
+	encoded, err := d.AvroCodec.BinaryFromNative(d.hdr, key)
+	if err != nil {
+		slog.Error("Error encoding Avro key", "Error", err)
+		return nil, err
+	}
+	return encoded, nil
+}
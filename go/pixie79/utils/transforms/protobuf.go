@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"log/slog"
+	pUtils "pixie79/utils"
+
+	transform "github.com/redpanda-data/redpanda/src/transform-sdk/go/transform"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// protobufSchemas holds compiled Protobuf schemas by schema ID; compiling a schema is far more
+// expensive than decoding a message so each one is compiled once per transform instance.
+var protobufSchemas = map[int]*pUtils.ProtobufSchema{}
+
+// getProtobufSchema returns the compiled Protobuf schema registered under the ID.
+func getProtobufSchema(id int) (*pUtils.ProtobufSchema, error) {
+	if schema, ok := protobufSchemas[id]; ok {
+		return schema, nil
+	}
+
+	registered, err := lookupSchema(id)
+	if err != nil {
+		return nil, err
+	}
+	references, err := pUtils.ResolveReferenceSources(toSchemaReferences(registered.References), resolveReference)
+	if err != nil {
+		slog.Error("Error resolving Protobuf references", "schemaID", id, "Error", err)
+		return nil, err
+	}
+	schema, err := pUtils.CompileProtobufSchema(registered.Schema, references)
+	if err != nil {
+		return nil, err
+	}
+
+	protobufSchemas[id] = schema
+	return schema, nil
+}
+
+// DecodeProtobufRawEvent decodes a Protobuf wire-format record value into a nested map keyed by the
+// .proto field names.
+func DecodeProtobufRawEvent(e transform.WriteEvent) (map[string]interface{}, error) {
+	sourceSchemaID, indexes, payload, err := pUtils.DecodeProtobufBuffer(e.Record().Value)
+	if err != nil {
+		slog.Error("Unable to read schema registry header", "Error", err)
+		return nil, err
+	}
+
+	schema, err := getProtobufSchema(sourceSchemaID)
+	if err != nil {
+		slog.Error("Error retrieving source schema", "Error", err)
+		return nil, err
+	}
+	descriptor, err := schema.MessageByIndexes(indexes)
+	if err != nil {
+		slog.Error("Unable to find Protobuf message", "Error", err)
+		return nil, err
+	}
+
+	nestedMap, err := pUtils.DecodeProtobuf(descriptor, payload)
+	if err != nil {
+		slog.Error("Unable to decode Protobuf event", "Error", err)
+		return nil, err
+	}
+	return nestedMap, nil
+}
+
+// EncodeProtobufRecord encodes the nested map as the Protobuf message and prefixes it with hdr,
+// which must be the header returned by pUtils.EncodeProtobufBuffer for the message.
+func EncodeProtobufRecord(nestedMap map[string]interface{}, descriptor protoreflect.MessageDescriptor, hdr []byte, key []byte, headers []transform.RecordHeader) (transform.Record, error) {
+	encoded, err := pUtils.EncodeProtobuf(descriptor, nestedMap)
+	if err != nil {
+		slog.Error("Error encoding Protobuf", "Error", err)
+		return transform.Record{}, err
+	}
+
+	record := transform.Record{
+		Key:     key,
+		Value:   append(append([]byte{}, hdr...), encoded...),
+		Headers: headers,
+	}
+
+	return record, nil
+}
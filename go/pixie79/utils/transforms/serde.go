@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	pUtils "pixie79/utils"
+
+	avro "github.com/linkedin/goavro/v2"
+	transform "github.com/redpanda-data/redpanda/src/transform-sdk/go/transform"
+	sr "github.com/redpanda-data/redpanda/src/transform-sdk/go/transform/sr"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// DecodeRawEvent decodes a schema registry encoded record value into a nested map, choosing the
+// decoder from the type of the schema the value was written with.
+func DecodeRawEvent(e transform.WriteEvent) (map[string]interface{}, sr.SchemaType, error) {
+	sourceSchemaID, _, err := pUtils.DecodeBuffer(e.Record().Value)
+	if err != nil {
+		slog.Error("Unable to read schema registry header", "Error", err)
+		return nil, 0, err
+	}
+
+	schemaType, err := getSchemaType(sourceSchemaID)
+	if err != nil {
+		slog.Error("Error retrieving source schema", "Error", err)
+		return nil, 0, err
+	}
+
+	var nestedMap map[string]interface{}
+	switch schemaType {
+	case sr.TypeAvro:
+		nestedMap, err = DecodeAvroRawEvent(e)
+	case sr.TypeProtobuf:
+		nestedMap, err = DecodeProtobufRawEvent(e)
+	case sr.TypeJSON:
+		nestedMap, err = DecodeJSONRawEvent(e)
+	default:
+		err = fmt.Errorf("unsupported schema type %d for schema ID %d", schemaType, sourceSchemaID)
+	}
+	return nestedMap, schemaType, err
+}
+
+// Destination encodes records in the format of the destination schema.
+//
+// Records are re-encoded from the generic nested map, so the source and destination schemas
+// must use the same format.
+type Destination struct {
+	SchemaID   int
+	Type       sr.SchemaType
+	AvroCodec  *avro.Codec
+	Message    protoreflect.MessageDescriptor
+	JSONSchema *pUtils.JSONSchema
+	hdr        []byte
+	// avroResolvers holds a resolver per source schema ID; nil when the source matches the destination
+	avroResolvers map[int]*pUtils.AvroResolver
+}
+
+// FetchDestination resolves the destination schema from DESTINATION_SUBJECT and DESTINATION_SCHEMA_VERSION,
+// or from DESTINATION_SCHEMA_ID. For Protobuf schemas DESTINATION_MESSAGE selects the message by fully
+// qualified name, defaulting to the first message.
+//
+// For Avro destinations single-object encoded values written with the destination schema, or with a
+// schema listed in SINGLE_OBJECT_SUBJECTS, are decoded as well.
+func FetchDestination() (*Destination, error) {
+	schemaID, err := destinationSchemaID()
+	if err != nil {
+		return nil, err
+	}
+	destination, err := newDestination(schemaID, os.Getenv("DESTINATION_MESSAGE"))
+	if err != nil || destination.Type != sr.TypeAvro {
+		return destination, err
+	}
+
+	if err := RegisterSingleObjectSchema(schemaID); err != nil {
+		return nil, err
+	}
+	if err := RegisterSingleObjectSubjects(os.Getenv("SINGLE_OBJECT_SUBJECTS")); err != nil {
+		return nil, err
+	}
+	return destination, nil
+}
+
+func newDestination(schemaID int, messageName string) (*Destination, error) {
+	schema, err := lookupSchema(schemaID)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving destination schema: %w", err)
+	}
+
+	destination := &Destination{SchemaID: schemaID, Type: schema.Type}
+	switch schema.Type {
+	case sr.TypeAvro:
+		destination.AvroCodec, err = codecCache.Get(schemaID)
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving destination schema: %w", err)
+		}
+		destination.hdr = pUtils.EncodeBuffer(schemaID)
+	case sr.TypeProtobuf:
+		protobufSchema, err := getProtobufSchema(schemaID)
+		if err != nil {
+			return nil, fmt.Errorf("error compiling destination schema: %w", err)
+		}
+		message, indexes, err := protobufSchema.MessageByName(messageName)
+		if err != nil {
+			return nil, err
+		}
+		destination.Message = message
+		destination.hdr = pUtils.EncodeProtobufBuffer(schemaID, indexes)
+	case sr.TypeJSON:
+		destination.JSONSchema, err = getJSONSchema(schemaID)
+		if err != nil {
+			return nil, fmt.Errorf("error compiling destination schema: %w", err)
+		}
+		destination.hdr = pUtils.EncodeBuffer(schemaID)
+	default:
+		return nil, fmt.Errorf("unsupported destination schema type %d", schema.Type)
+	}
+
+	slog.Debug("Destination schema", "schemaID", schemaID, "type", schema.Type)
+	return destination, nil
+}
+
+// DecodeRecord decodes a record value like DecodeRawEvent. Avro records are projected onto the
+// destination schema, so records written with any compatible version of the source schema can be
+// re-encoded for the destination topic. Single-object encoded Avro values are decoded by the
+// fingerprint of their writer schema.
+func (d *Destination) DecodeRecord(e transform.WriteEvent) (map[string]interface{}, sr.SchemaType, error) {
+	if pUtils.IsSingleObject(e.Record().Value) {
+		sourceSchemaID, nestedMap, err := DecodeSingleObjectEvent(e)
+		if err != nil || d.Type != sr.TypeAvro {
+			return nestedMap, sr.TypeAvro, err
+		}
+		nestedMap, err = d.resolveAvro(sourceSchemaID, nestedMap)
+		return nestedMap, sr.TypeAvro, err
+	}
+
+	nestedMap, schemaType, err := DecodeRawEvent(e)
+	if err != nil || schemaType != sr.TypeAvro || d.Type != sr.TypeAvro {
+		return nestedMap, schemaType, err
+	}
+
+	sourceSchemaID, _, err := pUtils.DecodeBuffer(e.Record().Value)
+	if err != nil {
+		return nil, schemaType, err
+	}
+	nestedMap, err = d.resolveAvro(sourceSchemaID, nestedMap)
+	return nestedMap, schemaType, err
+}
+
+// EncodeRecord encodes the nested map for the destination topic.
+func (d *Destination) EncodeRecord(nestedMap map[string]interface{}, key []byte, headers []transform.RecordHeader) (transform.Record, error) {
+	switch d.Type {
+	case sr.TypeProtobuf:
+		return EncodeProtobufRecord(nestedMap, d.Message, d.hdr, key, headers)
+	case sr.TypeJSON:
+		return EncodeJSONRecord(nestedMap, d.JSONSchema, d.hdr, key, headers)
+	default:
+		return EncodeAvroRecord(nestedMap, d.AvroCodec, d.hdr, key, headers)
+	}
+}
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"fmt"
+	"log/slog"
+	pUtils "pixie79/utils"
+	"strings"
+
+	transform "github.com/redpanda-data/redpanda/src/transform-sdk/go/transform"
+)
+
+// singleObjectSchemas maps the Rabin fingerprint of each known writer schema to its schema ID, as
+// single-object encoded values carry a fingerprint rather than a schema registry ID.
+var singleObjectSchemas = map[uint64]int{}
+
+// RegisterSingleObjectSchema makes values single-object encoded with the schema registered under
+// schemaID decodable.
+func RegisterSingleObjectSchema(schemaID int) error {
+	codec, err := codecCache.Get(schemaID)
+	if err != nil {
+		return fmt.Errorf("error retrieving schema %d: %w", schemaID, err)
+	}
+	singleObjectSchemas[codec.Rabin] = schemaID
+	slog.Debug("Registered single-object schema", "schemaID", schemaID, "fingerprint", fmt.Sprintf("%#016x", codec.Rabin))
+	return nil
+}
+
+// RegisterSingleObjectSubjects registers the schemas of a comma separated list of subject or
+// subject:version entries. Subjects without a version use the latest version.
+func RegisterSingleObjectSubjects(subjects string) error {
+	for _, entry := range strings.Split(subjects, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		subject, versionText := entry, ""
+		if idx := strings.LastIndex(entry, ":"); idx > 0 {
+			subject, versionText = entry[:idx], entry[idx+1:]
+		}
+		version, err := pUtils.ParseSchemaVersion(versionText)
+		if err != nil {
+			return fmt.Errorf("single-object subject %s: %w", entry, err)
+		}
+		schemaID, err := lookupSubjectSchemaID(subject, version)
+		if err != nil {
+			return fmt.Errorf("single-object subject %s: %w", entry, err)
+		}
+		if err := RegisterSingleObjectSchema(schemaID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeSingleObjectEvent decodes a single-object encoded record value into a nested map, returning
+// the ID of the registered schema whose fingerprint matches the value.
+func DecodeSingleObjectEvent(e transform.WriteEvent) (int, map[string]interface{}, error) {
+	fingerprint, payload, err := pUtils.DecodeSingleObject(e.Record().Value)
+	if err != nil {
+		slog.Error("Unable to read single-object header", "Error", err)
+		return 0, nil, err
+	}
+
+	schemaID, ok := singleObjectSchemas[fingerprint]
+	if !ok {
+		err := fmt.Errorf("no registered schema has fingerprint %#016x", fingerprint)
+		slog.Error("Unknown single-object schema", "Error", err)
+		return 0, nil, err
+	}
+
+	codec, err := codecCache.Get(schemaID)
+	if err != nil {
+		slog.Error("Error retrieving source schema", "Error", err)
+		return 0, nil, err
+	}
+	nestedMap, err := pUtils.DecodeAvroWithCodec(codec, payload)
+	if err != nil {
+		slog.Error("Unable to decode Avro event", "Error", err)
+		return 0, nil, err
+	}
+	return schemaID, nestedMap, nil
+}
@@ -1,30 +1,158 @@
 package utils
 
 import (
+	"errors"
+	"fmt"
 	"log/slog"
-	"strconv"
+	pUtils "pixie79/utils"
 
 	sr "github.com/redpanda-data/redpanda/src/transform-sdk/go/transform/sr"
 )
 
-// getSchema retrieves the schema with the given ID from the specified URL.
+// srClient is shared by every lookup rather than created per record. The SDK client does not cache
+// successful lookups, so compiled codecs are kept by CodecCache instead.
+var srClient sr.SchemaRegistryClient
+
+func schemaRegistryClient() sr.SchemaRegistryClient {
+	if srClient == nil {
+		srClient = sr.NewClient()
+	}
+	return srClient
+}
+
+// getSchema retrieves the schema with the given ID from the schema registry.
+//
+// Any schema references are resolved recursively and inlined, so the returned schema
+// can be compiled on its own.
 //
 // Parameters:
-// - id: The ID of the schema (as a string).
+// - id: The ID of the schema.
 //
 // Returns:
 // - The retrieved schema (as a string).
-func getSchema(id string) (string, error) {
-	client := sr.NewClient()
-	schemaID, err := strconv.Atoi(id)
+func getSchema(id int) (string, error) {
+	schema, err := lookupSchema(id)
 	if err != nil {
-		slog.Error("SCHEMA_ID not an integer:", "Error", id)
 		return "", err
 	}
-	schema, err := client.LookupSchemaById(schemaID)
+	return pUtils.ResolveAvroReferences(schema.Schema, toSchemaReferences(schema.References), resolveReference)
+}
+
+// schemaTypes holds the type of every schema looked up by ID, so decoding a record only asks the
+// registry for the type of a schema it has not seen before.
+var schemaTypes = map[int]sr.SchemaType{}
+
+// getSchemaType returns the type, Avro, Protobuf or JSON, of the schema registered under the ID.
+func getSchemaType(id int) (sr.SchemaType, error) {
+	if schemaType, ok := schemaTypes[id]; ok {
+		return schemaType, nil
+	}
+	schema, err := lookupSchema(id)
+	if err != nil {
+		return 0, err
+	}
+	schemaTypes[id] = schema.Type
+	return schema.Type, nil
+}
+
+// lookupSchema retrieves the registered schema, its type and references, from the schema registry.
+func lookupSchema(id int) (*sr.Schema, error) {
+	schema, err := schemaRegistryClient().LookupSchemaById(id)
 	if err != nil {
 		slog.Error("Unable to retrieve schema for ID", "Error", id)
-		return "", err
+		return nil, err
+	}
+	return schema, nil
+}
+
+// lookupSubjectSchemaID returns the global ID of the schema registered under subject and version.
+func lookupSubjectSchemaID(subject string, version int) (int, error) {
+	schema, err := schemaRegistryClient().LookupSchemaByVersion(subject, version)
+	if err != nil {
+		return 0, err
+	}
+	if schema == nil {
+		return 0, fmt.Errorf("unable to find a schema %s with version %d", subject, version)
+	}
+	return schema.ID, nil
+}
+
+// destinationSchemaID resolves the destination schema ID from the environment.
+func destinationSchemaID() (int, error) {
+	destination, err := pUtils.DestinationSchemaFromEnv()
+	if err != nil {
+		return 0, err
+	}
+	schemaID, err := destination.Resolve(lookupSubjectSchemaID)
+	if err != nil {
+		return 0, err
+	}
+	slog.Info("Resolved destination schema", "destination", destination.String(), "schemaID", schemaID)
+	return schemaID, nil
+}
+
+// keySchemaID resolves the destination key schema ID from the environment, falling back to the key
+// subject of defaultTopic. It returns false when no key schema is configured or registered, and an
+// error when the registry fails.
+func keySchemaID(defaultTopic string) (int, bool, error) {
+	destination, err := pUtils.SchemaFromEnv("DESTINATION_KEY")
+	if errors.Is(err, pUtils.ErrSchemaNotConfigured) {
+		if defaultTopic == "" {
+			return 0, false, nil
+		}
+		subject := pUtils.KeySubject(defaultTopic)
+		schemaID, err := lookupSubjectSchemaID(subject, pUtils.LatestSchemaVersion)
+		if err != nil {
+			// The SDK reports every registry failure alike, so the key subject only counts as not
+			// registered while the destination schema can still be looked up
+			if probeErr := registryAvailable(); probeErr != nil {
+				return 0, false, fmt.Errorf("unable to look up key subject %s: %w", subject, errors.Join(err, probeErr))
+			}
+			slog.Info("No key schema registered, keys are copied unchanged", "subject", subject)
+			return 0, false, nil
+		}
+		return schemaID, true, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	schemaID, err := destination.Resolve(lookupSubjectSchemaID)
+	if err != nil {
+		return 0, false, err
+	}
+	return schemaID, true, nil
+}
+
+// registryAvailable looks the destination schema up again, returning the error when the schema
+// registry cannot be used.
+func registryAvailable() error {
+	schemaID, err := destinationSchemaID()
+	if err != nil {
+		return err
+	}
+	_, err = lookupSchema(schemaID)
+	return err
+}
+
+// resolveReference fetches a referenced schema by subject and version.
+func resolveReference(subject string, version int) (string, []pUtils.SchemaReference, error) {
+	schema, err := schemaRegistryClient().LookupSchemaByVersion(subject, version)
+	if err != nil {
+		slog.Error("Unable to retrieve referenced schema", "subject", subject, "version", version)
+		return "", nil, err
+	}
+	return schema.Schema.Schema, toSchemaReferences(schema.References), nil
+}
+
+func toSchemaReferences(references []sr.Reference) []pUtils.SchemaReference {
+	converted := make([]pUtils.SchemaReference, len(references))
+	for i, reference := range references {
+		converted[i] = pUtils.SchemaReference{
+			Name:    reference.Name,
+			Subject: reference.Subject,
+			Version: reference.Version,
+		}
 	}
-	return schema.Schema, err
+	return converted
 }
@@ -0,0 +1,217 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// AvroUnionBranch returns the branch of the union a Go value is written with. A nil value uses the
+// null branch. Named types prefer the record, enum or fixed branch of the same name, records prefer
+// the first branch whose required fields they all have, and any other value takes the first branch
+// that accepts its Go type.
+func AvroUnionBranch(union *AvroSchema, value interface{}) (*AvroSchema, error) {
+	branch := unionBranchFor(union, derefValue(reflect.ValueOf(value)))
+	if branch == nil {
+		return nil, fmt.Errorf("no branch of the union accepts %T", value)
+	}
+	return branch, nil
+}
+
+// WrapAvroUnion wraps a value in the goavro native form of the union, keyed by the name goavro
+// gives the branch it is written with: the full name of records, enums and fixed types, or the
+// type and logical type such as int.date and bytes.decimal. Values that are already wrapped are
+// returned unchanged, and unions nested inside the value are wrapped as well.
+func WrapAvroUnion(union *AvroSchema, value interface{}) (interface{}, error) {
+	return wrapAvroUnion(union, value, "")
+}
+
+// WrapAvroUnions walks goavro native data written for the schema and wraps every union value that
+// is not already wrapped, so converters can leave nullable and multi-type fields as plain values.
+func WrapAvroUnions(schema *AvroSchema, native interface{}) (interface{}, error) {
+	return wrapAvroUnions(schema, native, "")
+}
+
+func wrapAvroUnions(schema *AvroSchema, native interface{}, path string) (interface{}, error) {
+	switch schema.Type {
+	case "union":
+		return wrapAvroUnion(schema, native, path)
+	case "record", "error":
+		record, ok := native.(map[string]interface{})
+		if !ok {
+			return native, nil
+		}
+		wrapped := make(map[string]interface{}, len(record))
+		for name, value := range record {
+			wrapped[name] = value
+		}
+		for _, field := range schema.Fields {
+			value, ok := record[field.Name]
+			if !ok {
+				continue
+			}
+			converted, err := wrapAvroUnions(field.Type, value, joinPath(path, field.Name))
+			if err != nil {
+				return nil, err
+			}
+			wrapped[field.Name] = converted
+		}
+		return wrapped, nil
+	case "array":
+		items, ok := native.([]interface{})
+		if !ok {
+			return native, nil
+		}
+		wrapped := make([]interface{}, len(items))
+		for i, item := range items {
+			converted, err := wrapAvroUnions(schema.Items, item, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			wrapped[i] = converted
+		}
+		return wrapped, nil
+	case "map":
+		values, ok := native.(map[string]interface{})
+		if !ok {
+			return native, nil
+		}
+		wrapped := make(map[string]interface{}, len(values))
+		for key, value := range values {
+			converted, err := wrapAvroUnions(schema.Values, value, joinPath(path, key))
+			if err != nil {
+				return nil, err
+			}
+			wrapped[key] = converted
+		}
+		return wrapped, nil
+	}
+
+	if v := derefValue(reflect.ValueOf(native)); v.IsValid() {
+		return v.Interface(), nil
+	}
+	return nil, nil
+}
+
+func wrapAvroUnion(union *AvroSchema, value interface{}, path string) (interface{}, error) {
+	v := derefValue(reflect.ValueOf(value))
+	if !v.IsValid() {
+		for _, branch := range union.Branches {
+			if branch.Type == "null" {
+				return nil, nil
+			}
+		}
+		return nil, fmt.Errorf("%s: null value for a union without a null branch", displayPath(path))
+	}
+
+	if wrapped, ok := v.Interface().(map[string]interface{}); ok && len(wrapped) == 1 {
+		for name, inner := range wrapped {
+			for _, branch := range union.Branches {
+				if branch.BranchName() != name {
+					continue
+				}
+				converted, err := wrapAvroUnions(branch, inner, path)
+				if err != nil {
+					return nil, err
+				}
+				return map[string]interface{}{name: converted}, nil
+			}
+		}
+	}
+
+	branch := unionBranchFor(union, v)
+	if branch == nil {
+		return nil, fmt.Errorf("%s: no branch of the union accepts %s", displayPath(path), v.Type())
+	}
+	converted, err := wrapAvroUnions(branch, v.Interface(), path)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{branch.BranchName(): converted}, nil
+}
+
+// unionBranchFor picks the union branch for a dereferenced Go value, or nil when none accepts it.
+func unionBranchFor(union *AvroSchema, v reflect.Value) *AvroSchema {
+	if !v.IsValid() {
+		for _, branch := range union.Branches {
+			if branch.Type == "null" {
+				return branch
+			}
+		}
+		return nil
+	}
+
+	if typeName := v.Type().Name(); typeName != "" {
+		for _, branch := range union.Branches {
+			if branch.isNamed() && unqualifiedName(branch.Name) == typeName && fitsBranch(branch, v) {
+				return branch
+			}
+		}
+	}
+	if v.Kind() == reflect.Struct || v.Kind() == reflect.Map {
+		for _, branch := range union.Branches {
+			if (branch.Type == "record" || branch.Type == "error") && fitsBranch(branch, v) && hasRequiredFields(branch, v) {
+				return branch
+			}
+		}
+	}
+	for _, branch := range union.Branches {
+		if branch.Type != "null" && fitsBranch(branch, v) {
+			return branch
+		}
+	}
+	return nil
+}
+
+// fitsBranch reports whether a value can be written with a union branch. Beyond the Go type, enum
+// values must be one of the symbols and fixed values must have the fixed size, so a string falls
+// through to a string branch and a byte slice to a bytes branch when they don't fit.
+func fitsBranch(branch *AvroSchema, v reflect.Value) bool {
+	if !acceptsValue(branch, v) {
+		return false
+	}
+	switch {
+	case branch.Type == "enum":
+		for _, symbol := range branch.Symbols {
+			if symbol == v.String() {
+				return true
+			}
+		}
+		return false
+	case branch.Type == "fixed" && v.Type() != ratType:
+		return v.Len() == branch.Size
+	}
+	return true
+}
+
+// hasRequiredFields reports whether a struct or map has a value for every record field without a default.
+// Maps need string keys to hold record fields; the guard keeps Convert from panicking on any other key.
+func hasRequiredFields(record *AvroSchema, v reflect.Value) bool {
+	if v.Kind() == reflect.Map && v.Type().Key().Kind() != reflect.String {
+		return false
+	}
+	for _, field := range record.Fields {
+		if field.HasDefault {
+			continue
+		}
+		if v.Kind() == reflect.Map {
+			if !v.MapIndex(reflect.ValueOf(field.Name).Convert(v.Type().Key())).IsValid() {
+				return false
+			}
+		} else if _, ok := structFieldFor(v.Type(), field); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// derefValue follows pointers and interfaces, returning the zero Value for nil. Decimals stay
+// pointers, as goavro expects *big.Rat.
+func derefValue(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Interface || (v.Kind() == reflect.Pointer && v.Type() != ratType)) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
@@ -0,0 +1,121 @@
+package utils_test
+
+import (
+	"math/big"
+	"time"
+
+	pTypes "pixie79/types"
+	"pixie79/utils"
+
+	goavro "github.com/linkedin/goavro/v2"
+	. "github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+)
+
+var _ = Describe("Schema-driven union wrapping", func() {
+	const shipmentSchema = `{"type": "record", "name": "Shipment", "namespace": "com.demo", "fields": [
+		{"name": "status", "type": ["null", {"type": "enum", "name": "Status", "symbols": ["OPEN", "CLOSED"]}, "string"]},
+		{"name": "address", "type": ["null", {"type": "record", "name": "Address", "namespace": "com.demo.geo", "fields": [
+			{"name": "city", "type": "string"},
+			{"name": "note", "type": ["null", "string"], "default": null}
+		]}]},
+		{"name": "checksum", "type": ["null", {"type": "fixed", "name": "MD5", "size": 4}, "bytes"]},
+		{"name": "price", "type": ["null", {"type": "bytes", "logicalType": "decimal", "precision": 9, "scale": 2}]},
+		{"name": "trackingId", "type": ["null", {"type": "string", "logicalType": "uuid"}]},
+		{"name": "shipped", "type": ["null", {"type": "int", "logicalType": "date"}]},
+		{"name": "updated", "type": ["null", {"type": "long", "logicalType": "timestamp-millis"}]}
+	]}`
+
+	parse := func(source string) *utils.AvroSchema {
+		schema, err := utils.ParseAvroSchema(source)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		return schema
+	}
+
+	It("should name the branch goavro expects for every branch type", func() {
+		schema := parse(shipmentSchema)
+		shipped := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+		note := "leave at door"
+		record := map[string]interface{}{
+			"status":     "CLOSED",
+			"address":    map[string]interface{}{"city": "Leeds", "note": &note},
+			"checksum":   []byte{1, 2, 3, 4},
+			"price":      big.NewRat(1999, 100),
+			"trackingId": "5b9f3d8e-5d8a-4a52-9b36-0c3b8f7a6e01",
+			"shipped":    shipped,
+			"updated":    int64(1296997036167),
+		}
+
+		native, err := utils.WrapAvroUnions(schema, record)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		wrapped := native.(map[string]interface{})
+		gomega.Expect(wrapped["status"]).To(gomega.HaveKey("com.demo.Status"))
+		gomega.Expect(wrapped["address"]).To(gomega.HaveKey("com.demo.geo.Address"))
+		address := wrapped["address"].(map[string]interface{})["com.demo.geo.Address"].(map[string]interface{})
+		gomega.Expect(address["note"]).To(gomega.Equal(map[string]interface{}{"string": "leave at door"}))
+		gomega.Expect(wrapped["checksum"]).To(gomega.HaveKey("com.demo.MD5"))
+		gomega.Expect(wrapped["price"]).To(gomega.HaveKey("bytes.decimal"))
+		gomega.Expect(wrapped["trackingId"]).To(gomega.HaveKey("string"))
+		gomega.Expect(wrapped["shipped"]).To(gomega.Equal(map[string]interface{}{"int.date": shipped}))
+		gomega.Expect(wrapped["updated"]).To(gomega.HaveKey("long.timestamp-millis"))
+		// the caller's record is left as it was
+		gomega.Expect(record["status"]).To(gomega.Equal("CLOSED"))
+
+		codec, err := goavro.NewCodec(shipmentSchema)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		_, err = codec.BinaryFromNative(nil, native)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+	})
+
+	It("should fall through to other branches when a named type does not fit", func() {
+		schema := parse(shipmentSchema)
+		native, err := utils.WrapAvroUnions(schema, map[string]interface{}{
+			"status":   "LOST",
+			"checksum": []byte{1, 2, 3},
+			"address":  nil,
+		})
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		wrapped := native.(map[string]interface{})
+		gomega.Expect(wrapped["status"]).To(gomega.Equal(map[string]interface{}{"string": "LOST"}))
+		gomega.Expect(wrapped["checksum"]).To(gomega.HaveKey("bytes"))
+		gomega.Expect(wrapped["address"]).To(gomega.BeNil())
+	})
+
+	It("should leave wrapped values alone and report unions no branch accepts", func() {
+		schema := parse(shipmentSchema)
+		union := schema.Field("status").Type
+		value, err := utils.WrapAvroUnion(union, map[string]interface{}{"string": "LOST"})
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(value).To(gomega.Equal(map[string]interface{}{"string": "LOST"}))
+
+		branch, err := utils.AvroUnionBranch(union, "OPEN")
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(branch.BranchName()).To(gomega.Equal("com.demo.Status"))
+
+		_, err = utils.WrapAvroUnions(schema, map[string]interface{}{"shipped": true})
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("shipped: no branch of the union accepts bool")))
+	})
+
+	It("should encode the generated demo event once its unions are wrapped", func() {
+		givenName := "Tom"
+		dateOfBirth := pTypes.CustpTime{Time: time.Unix(11613*86400, 0).UTC()}
+		record := pTypes.CustomerEvent{
+			Metadata: pTypes.EventMetadata{MessageKey: "tnKGDKUndl", EventType: "INSERT"},
+			Payload:  pTypes.Customer{Id: "PKs-Is7j", GivenName: &givenName, DateOfBirth: &dateOfBirth},
+		}.ToAvro()
+
+		codec := demoCodec(GinkgoT())
+		schema := parse(codec.Schema())
+		native, err := utils.WrapAvroUnions(schema, record)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		encoded, err := codec.BinaryFromNative(nil, native)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+		decoded, err := utils.DecodeAvroWithCodec(codec, encoded)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		name, _ := utils.GetStringField(decoded, "payload.given_name")
+		gomega.Expect(name).To(gomega.Equal("Tom"))
+		born, _ := utils.GetField(decoded, "payload.date_of_birth")
+		gomega.Expect(born).To(gomega.Equal(dateOfBirth.Time))
+	})
+})
@@ -39,9 +39,9 @@ const (
 		"outbox_published_date": 1203458653655,
 		"event_type": "INSERT"
 		},
-		"business_data_payload": {
+		"payload": {
 			"id": "PKs-Is7j",
-			"name_prefix": "Mr",
+			"title": "Mr",
 			"preferred_name": "Tom",
 			"given_name": "Tom",
 			"last_name": "Jones",
@@ -58,9 +58,9 @@ const (
 		"outbox_published_date": 1203458653655,
 		"event_type": "INSERT"
 		},
-		"business_data_payload": {
+		"payload": {
 			"id": "PKs-Is7j",
-			"name_prefix": "Mr",
+			"title": "Mr",
 			"preferred_name": "Tom",
 			"given_name": "******",
 			"last_name": "******",
@@ -87,7 +87,7 @@ func TestDemo(t *testing.T) {
 		outputTopic = "output-demo"
 		wasmFile    = "../demo.wasm"
 		schemaFile  = "../../../../schemas/" + inputTopic + ".avsc"
-		recordType  = "demoEvent"
+		recordType  = "customerEvent"
 	)
 
 	t.Parallel()
@@ -117,7 +117,7 @@ func TestDemo(t *testing.T) {
 		slog.Error("Error creating record", "Error", err)
 	}
 
-	outputData1, err := pKgo.ConvertToAvroKgoRecord(recordType, []byte(testDataOutput1), hdr, destinationCodec, []byte("eventKey"), []transform.RecordHeader{}, inputTopic)
+	outputData1, err := pKgo.ConvertToAvroKgoRecord(recordType, []byte(testDataOutput1), hdr, destinationCodec, []byte("eventKey"), []transform.RecordHeader{}, outputTopic)
 	if err != nil {
 		slog.Error("Error creating record", "Error", err)
 	}
@@ -136,3 +136,152 @@ func TestDemo(t *testing.T) {
 	pTUtils.RequireRecordsEquals(t, fetches, outputData1)
 
 }
+
+func TestDemoRouting(t *testing.T) {
+	var (
+		inputTopic  = "demo-routing"
+		outputTopic = "output-demo-routing"
+		euTopic     = outputTopic + "-eu"
+		wasmFile    = "../demo.wasm"
+		schemaFile  = "../../../../schemas/demo.avsc"
+		recordType  = "customerEvent"
+	)
+
+	t.Parallel()
+	binary := pTUtils.LoadWasmFile(t, wasmFile)
+
+	_, _ = pTUtils.DeploySchema(t, inputTopic+"-value", schemaFile, ctx, schemaClient)
+	destinationSchemaId, destinationCodec := pTUtils.DeploySchema(t, euTopic+"-value", schemaFile, ctx, schemaClient)
+
+	metadata := pTUtils.TransformDeployMetadata{
+		Name:         outputTopic,
+		InputTopic:   inputTopic,
+		OutputTopics: []string{euTopic, outputTopic + "-us", outputTopic + "-quarantine"},
+		Environment: []pTUtils.EnvironmentVariable{
+			{Key: "DESTINATION_SUBJECT", Value: euTopic + "-value"},
+			{Key: "UNMASKED_CUSTOMERS", Value: "[{\\\"last_name\\\": \\\"Smith\\\",\\\"first_name\\\": \\\"Jane\\\"}]"},
+			{Key: "TRANSFORM_MODE", Value: "route"},
+			{Key: "REGION_MAP", Value: `{"UK": "eu", "USA": "us"}`},
+			{Key: "REGION_TOPIC_PREFIX", Value: outputTopic},
+		},
+	}
+
+	slog.Info("Deploying transform", "metadata", metadata)
+	pTUtils.DeployTransform(t, metadata, binary, ctx, kafkaAdminClient, adminClient)
+
+	hdr := pUtils.EncodeBuffer(destinationSchemaId)
+
+	inputData1, err := pKgo.ConvertToAvroKgoRecord(recordType, []byte(testDataInput1), hdr, destinationCodec, []byte("eventKey"), []transform.RecordHeader{}, inputTopic)
+	require.NoError(t, err)
+
+	outputData1, err := pKgo.ConvertToAvroKgoRecord(recordType, []byte(testDataOutput1), hdr, destinationCodec, []byte("eventKey"), []transform.RecordHeader{}, euTopic)
+	require.NoError(t, err)
+
+	client := pTUtils.MakeClient(t, ctx, container, kgo.DefaultProduceTopic(inputTopic), kgo.ConsumeTopics(euTopic))
+	defer client.Close()
+
+	// A UK resident is expected on the EU topic
+	err = client.ProduceSync(ctx, inputData1).FirstErr()
+	require.NoError(t, err)
+	fetches := client.PollFetches(ctx)
+	pTUtils.RequireRecordsEquals(t, fetches, outputData1)
+}
+
+func TestDemoNoSchemaJSON(t *testing.T) {
+	var (
+		inputTopic  = "demo-json"
+		outputTopic = "output-demo-json"
+		wasmFile    = "../demo.wasm"
+		schemaFile  = "../../../../schemas/demo.avsc"
+	)
+
+	t.Parallel()
+	binary := pTUtils.LoadWasmFile(t, wasmFile)
+
+	destinationSchemaId, _ := pTUtils.DeploySchema(t, outputTopic+"-value", schemaFile, ctx, schemaClient)
+
+	metadata := pTUtils.TransformDeployMetadata{
+		Name:         outputTopic,
+		InputTopic:   inputTopic,
+		OutputTopics: []string{outputTopic},
+		Environment: []pTUtils.EnvironmentVariable{
+			{Key: "DESTINATION_SCHEMA_ID", Value: strconv.Itoa(destinationSchemaId)},
+			{Key: "UNMASKED_CUSTOMERS", Value: "[{\\\"last_name\\\": \\\"Smith\\\",\\\"first_name\\\": \\\"Jane\\\"}]"},
+			{Key: "NO_SCHEMA_POLICY", Value: "json"},
+		},
+	}
+
+	slog.Info("Deploying transform", "metadata", metadata)
+	pTUtils.DeployTransform(t, metadata, binary, ctx, kafkaAdminClient, adminClient)
+
+	client := pTUtils.MakeClient(t, ctx, container, kgo.DefaultProduceTopic(inputTopic), kgo.ConsumeTopics(outputTopic))
+	defer client.Close()
+
+	// Plain JSON without a schema registry header is masked and written back as JSON
+	input := &kgo.Record{Key: []byte("eventKey"), Value: []byte(`{"payload": {"id": "PKs-Is7j", "given_name": "Tom", "last_name": "Jones"}}`)}
+	err := client.ProduceSync(ctx, input).FirstErr()
+	require.NoError(t, err)
+	fetches := client.PollFetches(ctx)
+	require.NoError(t, fetches.Err0())
+
+	records := fetches.Records()
+	require.Len(t, records, 1)
+	masked, err := pUtils.DecodeJSON(records[0].Value)
+	require.NoError(t, err)
+	givenName, _ := pUtils.GetStringField(masked, "payload.given_name")
+	require.Equal(t, "******", givenName)
+	id, _ := pUtils.GetStringField(masked, "payload.id")
+	require.Equal(t, "PKs-Is7j", id)
+}
+
+func TestDemoSingleObject(t *testing.T) {
+	var (
+		inputTopic  = "demo-soe"
+		outputTopic = "output-demo-soe"
+		wasmFile    = "../demo.wasm"
+		schemaFile  = "../../../../schemas/demo.avsc"
+		recordType  = "customerEvent"
+	)
+
+	t.Parallel()
+	binary := pTUtils.LoadWasmFile(t, wasmFile)
+
+	destinationSchemaId, destinationCodec := pTUtils.DeploySchema(t, outputTopic+"-value", schemaFile, ctx, schemaClient)
+
+	metadata := pTUtils.TransformDeployMetadata{
+		Name:         outputTopic,
+		InputTopic:   inputTopic,
+		OutputTopics: []string{outputTopic},
+		Environment: []pTUtils.EnvironmentVariable{
+			{Key: "DESTINATION_SCHEMA_ID", Value: strconv.Itoa(destinationSchemaId)},
+			{Key: "UNMASKED_CUSTOMERS", Value: "[{\\\"last_name\\\": \\\"Smith\\\",\\\"first_name\\\": \\\"Jane\\\"}]"},
+		},
+	}
+
+	slog.Info("Deploying transform", "metadata", metadata)
+	pTUtils.DeployTransform(t, metadata, binary, ctx, kafkaAdminClient, adminClient)
+
+	hdr := pUtils.EncodeBuffer(destinationSchemaId)
+
+	wireInput, err := pKgo.ConvertToAvroKgoRecord(recordType, []byte(testDataInput1), hdr, destinationCodec, []byte("eventKey"), []transform.RecordHeader{}, inputTopic)
+	require.NoError(t, err)
+	_, payload, err := pUtils.DecodeBuffer(wireInput.Value)
+	require.NoError(t, err)
+	native, err := pUtils.DecodeAvroWithCodec(destinationCodec, payload)
+	require.NoError(t, err)
+
+	// The same record single-object encoded is recognised by the fingerprint of the destination schema
+	inputData1 := &kgo.Record{Key: []byte("eventKey")}
+	inputData1.Value, err = pUtils.EncodeSingleObject(destinationCodec, native)
+	require.NoError(t, err)
+
+	outputData1, err := pKgo.ConvertToAvroKgoRecord(recordType, []byte(testDataOutput1), hdr, destinationCodec, []byte("eventKey"), []transform.RecordHeader{}, inputTopic)
+	require.NoError(t, err)
+
+	client := pTUtils.MakeClient(t, ctx, container, kgo.DefaultProduceTopic(inputTopic), kgo.ConsumeTopics(outputTopic))
+	defer client.Close()
+	err = client.ProduceSync(ctx, inputData1).FirstErr()
+	require.NoError(t, err)
+	fetches := client.PollFetches(ctx)
+	pTUtils.RequireRecordsEquals(t, fetches, outputData1)
+}
@@ -1,27 +1,53 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"os"
+	"strconv"
+	"strings"
 
 	pUtils "pixie79/utils"
 	pTransforms "pixie79/utils/transforms"
 
-	avro "github.com/linkedin/goavro/v2"
 	transform "github.com/redpanda-data/redpanda/src/transform-sdk/go/transform"
 )
 
 var (
-	destinationCodec    *avro.Codec
-	hdr                 []byte
+	destination         *pTransforms.Destination
+	keyDestination      *pTransforms.Destination
 	unmaskedCustomerMap map[string]bool
+	maskingPolicy       *pUtils.MaskingPolicy
+	regionRouter        *pUtils.RegionRouter
+	eventActions        map[string]string
+	redactKeepFields    []string
+	deadLetters         *pTransforms.DeadLetterQueue
+	noSchemaPolicy      string
+	noSchemaTopic       string
+)
+
+const (
+	modeMask  = "mask"
+	modeRoute = "route"
+
+	defaultTransformName = "demo"
+)
+
+// Policies for records without a schema registry header.
+const (
+	noSchemaFail  = "fail"
+	noSchemaPass  = "pass"
+	noSchemaDrop  = "drop"
+	noSchemaRoute = "route"
+	noSchemaJSON  = "json"
 )
 
 func init() {
 	var (
 		err               error
 		unmaskedCustomers string
+		policyJSON        string
 	)
 
 	pUtils.SetupLogger()
@@ -33,23 +59,101 @@ func init() {
 	}
 	slog.Debug("UNMASKED_CUSTOMERS", "unmaskedCustomers", unmaskedCustomers)
 
-	destinationCodec, hdr, err = pTransforms.FetchAvroDestinationSchema()
+	if cacheSize := os.Getenv("CODEC_CACHE_SIZE"); cacheSize != "" {
+		size, err := strconv.Atoi(cacheSize)
+		if err != nil {
+			panic(fmt.Sprintf("CODEC_CACHE_SIZE not an integer: %s", cacheSize))
+		}
+		pTransforms.SetCodecCacheSize(size)
+	}
+
+	destination, err = pTransforms.FetchDestination()
 	if err != nil {
 		slog.Error("Error fetching destination schema", "Error", err)
 		panic(fmt.Sprintf("Error fetching destination schema: %v\n", err))
 	}
 
+	keyDestination, err = pTransforms.FetchKeyDestination()
+	if err != nil {
+		slog.Error("Error fetching key schema", "Error", err)
+		panic(fmt.Sprintf("Error fetching key schema: %v\n", err))
+	}
+
 	_, unmaskedCustomerMap, err = pUtils.UnmarshalCustomers(unmaskedCustomers)
 	if err != nil {
 		slog.Error("Error unmarshalling customers", "Error", err)
 	}
 	slog.Debug("Not Masking Customers with the last_name", "unmaskedCustomerMap", unmaskedCustomerMap)
 
+	policyJSON = os.Getenv("MASKING_POLICY")
+	if policyJSON == "" {
+		maskingPolicy = pUtils.DefaultMaskingPolicy()
+	} else {
+		maskingPolicy, err = pUtils.UnmarshalMaskingPolicy(policyJSON, os.Getenv("TOKENISE_KEY"))
+		if err != nil {
+			slog.Error("Error unmarshalling masking policy", "Error", err)
+			panic(fmt.Sprintf("Error unmarshalling masking policy: %v\n", err))
+		}
+	}
+	slog.Debug("MASKING_POLICY", "maskingPolicy", maskingPolicy)
+
+	switch mode := os.Getenv("TRANSFORM_MODE"); mode {
+	case "", modeMask:
+	case modeRoute:
+		regionRouter, err = pUtils.NewRegionRouter(os.Getenv("ROUTING_FIELD"), os.Getenv("REGION_MAP"), os.Getenv("REGION_TOPIC_PREFIX"), os.Getenv("QUARANTINE_TOPIC"))
+		if err != nil {
+			slog.Error("Error configuring region routing", "Error", err)
+			panic(fmt.Sprintf("Error configuring region routing: %v\n", err))
+		}
+		slog.Info("Routing records by region", "field", regionRouter.Field, "topics", regionRouter.Topics())
+	default:
+		slog.Error("Unknown TRANSFORM_MODE", "mode", mode)
+		panic(fmt.Sprintf("Unknown TRANSFORM_MODE: %s", mode))
+	}
+
+	eventActions = map[string]string{}
+	if actionsJSON := os.Getenv("EVENT_TYPE_ACTIONS"); actionsJSON != "" {
+		eventActions, err = pUtils.UnmarshalEventActions(actionsJSON)
+		if err != nil {
+			slog.Error("Error unmarshalling event type actions", "Error", err)
+			panic(fmt.Sprintf("Error unmarshalling event type actions: %v\n", err))
+		}
+	}
+	slog.Debug("EVENT_TYPE_ACTIONS", "eventActions", eventActions)
+
+	redactKeepFields = pUtils.DefaultRedactKeepFields
+	if keepFields := os.Getenv("REDACT_KEEP_FIELDS"); keepFields != "" {
+		redactKeepFields = strings.Split(keepFields, ",")
+	}
+
+	switch noSchemaPolicy = os.Getenv("NO_SCHEMA_POLICY"); noSchemaPolicy {
+	case "":
+		noSchemaPolicy = noSchemaFail
+	case noSchemaFail, noSchemaPass, noSchemaDrop, noSchemaJSON:
+	case noSchemaRoute:
+		noSchemaTopic = os.Getenv("NO_SCHEMA_TOPIC")
+		if noSchemaTopic == "" {
+			panic("NO_SCHEMA_TOPIC environment variable is required when NO_SCHEMA_POLICY is route")
+		}
+	default:
+		slog.Error("Unknown NO_SCHEMA_POLICY", "policy", noSchemaPolicy)
+		panic(fmt.Sprintf("Unknown NO_SCHEMA_POLICY: %s", noSchemaPolicy))
+	}
+	slog.Debug("NO_SCHEMA_POLICY", "policy", noSchemaPolicy, "topic", noSchemaTopic)
+
+	if dlqTopic := os.Getenv("DLQ_TOPIC"); dlqTopic != "" {
+		transformName := os.Getenv("TRANSFORM_NAME")
+		if transformName == "" {
+			transformName = defaultTransformName
+		}
+		deadLetters = pTransforms.NewDeadLetterQueue(dlqTopic, transformName, os.Getenv("REDPANDA_INPUT_TOPIC"))
+		slog.Info("Writing failed records to dead-letter topic", "topic", dlqTopic)
+	}
 }
 
 func main() {
 	slog.Info("Running transformer")
-	transform.OnRecordWritten(toAvro)
+	transform.OnRecordWritten(transformRecord)
 }
 
 // func toAvro(e transform.WriteEvent, w transform.RecordWriter) error {
@@ -57,49 +161,184 @@ func main() {
 // 	return w.Write(e.Record())
 // }
 
-func toAvro(e transform.WriteEvent, w transform.RecordWriter) error {
-	var (
-		LastName  string
-		GivenName string
-		err       error
-	)
+func transformRecord(e transform.WriteEvent, w transform.RecordWriter) error {
+	if value := e.Record().Value; !pUtils.IsSingleObject(value) {
+		if _, _, err := pUtils.DecodeBuffer(value); err != nil {
+			return transformSchemaless(e, w, err)
+		}
+	}
+
 	// Decode the raw event
-	nestedMap, err := pTransforms.DecodeAvroRawEvent(e)
+	nestedMap, _, err := destination.DecodeRecord(e)
+	if err != nil {
+		slog.Error("Error decoding record", "Error", err)
+		return deadLetter(w, e, pUtils.DeadLetterStageDecode, err)
+	}
+
+	key, keyMap, err := decodeKey(e)
+	if err != nil {
+		return deadLetter(w, e, pUtils.DeadLetterStageDecode, err)
+	}
+
+	topic, tombstone := processRecord(nestedMap, keyMap)
+
+	encodedKey, err := encodeKey(e, key)
+	if err != nil {
+		return deadLetter(w, e, pUtils.DeadLetterStageEncode, err)
+	}
+	if tombstone {
+		return writeRecord(w, transform.Record{Key: encodedKey, Headers: e.Record().Headers}, topic)
+	}
+
+	record, err := destination.EncodeRecord(nestedMap, encodedKey, e.Record().Headers)
 	if err != nil {
-		slog.Error("Error decoding Avro", "Error", err)
-		return err
-	}
-
-	Payload := nestedMap["payload"].(map[string]interface{})
-
-	ln, ok := Payload["last_name"].(map[string]interface{})
-	if ok {
-		LastName = ln["string"].(string)
-		if ok {
-			// Check if the last name is in the list of customers to not mask
-			if !pUtils.StringInMap(LastName, unmaskedCustomerMap) {
-				if gn, ok := Payload["given_name"].(map[string]interface{}); ok {
-					GivenName = gn["string"].(string)
-				}
-
-				Payload["given_name"] = pUtils.WrapUnionSimple(pUtils.MaskString(GivenName, "*", "fixed", 6), "string")
-				Payload["last_name"] = pUtils.WrapUnionSimple(pUtils.MaskString(LastName, "*", "fixed", 6), "string")
-				slog.Debug("Customer found - masking.")
-			} else {
-				slog.Info("Unmasked Customer found - not masking.")
-			}
-		} else {
-			slog.Debug("Last name not available.")
+		slog.Error("Error encoding record", "Error", err)
+		return deadLetter(w, e, pUtils.DeadLetterStageEncode, err)
+	}
+	return writeRecord(w, record, topic)
+}
+
+// transformSchemaless applies NO_SCHEMA_POLICY to a record with neither a schema registry header nor
+// a single-object encoding header.
+func transformSchemaless(e transform.WriteEvent, w transform.RecordWriter, cause error) error {
+	original := transform.Record{
+		Key:     e.Record().Key,
+		Value:   e.Record().Value,
+		Headers: e.Record().Headers,
+	}
+
+	switch noSchemaPolicy {
+	case noSchemaPass:
+		slog.Debug("Passing through record without schema")
+		return writeRecord(w, original, "")
+	case noSchemaDrop:
+		slog.Debug("Dropping record without schema")
+		return nil
+	case noSchemaRoute:
+		slog.Debug("Routing record without schema", "topic", noSchemaTopic)
+		return writeRecord(w, original, noSchemaTopic)
+	case noSchemaJSON:
+		nestedMap, err := pUtils.DecodeJSON(original.Value)
+		if err != nil {
+			return deadLetter(w, e, pUtils.DeadLetterStageDecode, err)
+		}
+
+		topic, tombstone := processRecord(nestedMap, nil)
+		if tombstone {
+			return writeRecord(w, transform.Record{Key: original.Key, Headers: original.Headers}, topic)
+		}
+
+		original.Value, err = json.Marshal(nestedMap)
+		if err != nil {
+			slog.Error("Error encoding JSON record", "Error", err)
+			return deadLetter(w, e, pUtils.DeadLetterStageEncode, err)
+		}
+		return writeRecord(w, original, topic)
+	default:
+		slog.Error("Error decoding record", "Error", cause)
+		return deadLetter(w, e, pUtils.DeadLetterStageDecode, cause)
+	}
+}
+
+// processRecord routes the decoded record and applies the action for its event type in place. It
+// returns the topic to write to, empty for the default output topic, and whether a tombstone
+// should be written instead of the record.
+func processRecord(nestedMap map[string]interface{}, keyMap map[string]interface{}) (string, bool) {
+	var topic string
+
+	// Route before any redaction removes the routing field
+	if regionRouter != nil {
+		var quarantined bool
+		topic, quarantined = regionRouter.TopicFor(nestedMap)
+		if quarantined {
+			slog.Warn("Country unknown - quarantining record", "topic", topic)
 		}
-	} else {
-		slog.Debug("Last name field not found.")
 	}
 
-	record, err := pTransforms.EncodeAvroRecord(nestedMap, destinationCodec, hdr, e.Record().Key, e.Record().Headers)
+	// Key rules apply whatever the event action, so tombstones keep matching the masked keys of the
+	// records they delete
+	unmasked := isUnmaskedCustomer(nestedMap)
+	if keyMap != nil && !unmasked {
+		maskingPolicy.ApplyToKey(nestedMap, keyMap)
+	}
+
+	switch pUtils.EventActionFor(nestedMap, eventActions) {
+	case pUtils.EventActionTombstone:
+		slog.Debug("Emitting tombstone")
+		return topic, true
+	case pUtils.EventActionRedact:
+		slog.Debug("Redacting record", "keep", redactKeepFields)
+		pUtils.RedactRecord(nestedMap, redactKeepFields)
+	default:
+		maskRecord(nestedMap, unmasked)
+	}
+	return topic, false
+}
+
+// decodeKey decodes an Avro encoded record key when a key schema is configured. The key map is set
+// when the key is a record, so the masking policy can apply to it.
+func decodeKey(e transform.WriteEvent) (interface{}, map[string]interface{}, error) {
+	if keyDestination == nil {
+		return nil, nil, nil
+	}
+	key, ok, err := keyDestination.DecodeKey(e.Record().Key)
+	if err != nil {
+		slog.Error("Error decoding key", "Error", err)
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, nil
+	}
+	keyMap, _ := key.(map[string]interface{})
+	return key, keyMap, nil
+}
+
+// encodeKey re-encodes a decoded key for the destination key schema, or returns the original key
+// when it was not decoded.
+func encodeKey(e transform.WriteEvent, key interface{}) ([]byte, error) {
+	if key == nil {
+		return e.Record().Key, nil
+	}
+	encoded, err := keyDestination.EncodeKey(key)
 	if err != nil {
-		slog.Error("Error encoding Avro", "Error", err)
-		return err
+		slog.Error("Error encoding key", "Error", err)
+		return nil, err
+	}
+	return encoded, nil
+}
+
+// maskRecord applies the masking policy unless the customer is in the list of customers to not mask.
+func maskRecord(nestedMap map[string]interface{}, unmasked bool) {
+	if unmasked {
+		slog.Info("Unmasked Customer found - not masking.")
+		return
+	}
+	jurisdiction := maskingPolicy.Apply(nestedMap)
+	slog.Debug("Customer masked.", "jurisdiction", jurisdiction)
+}
+
+// isUnmaskedCustomer reports whether the record belongs to a customer whose last name is in the list
+// of customers to not mask.
+func isUnmaskedCustomer(nestedMap map[string]interface{}) bool {
+	lastName, ok := pUtils.GetStringField(nestedMap, "payload.last_name")
+	return ok && pUtils.StringInMap(lastName, unmaskedCustomerMap)
+}
+
+// deadLetter writes the failed record to the dead-letter topic so processing continues, or returns
+// the error when no dead-letter topic is configured.
+func deadLetter(w transform.RecordWriter, e transform.WriteEvent, stage string, cause error) error {
+	if deadLetters == nil {
+		return cause
+	}
+	return deadLetters.Write(w, e, stage, cause)
+}
+
+// writeRecord writes the record to topic, or to the default output topic when topic is empty.
+func writeRecord(w transform.RecordWriter, record transform.Record, topic string) error {
+	if topic != "" {
+		slog.Debug("Returning record", "record", record, "topic", topic)
+		return w.Write(record, transform.ToTopic(topic))
 	}
-	slog.Debug("Returning AVRO", "record", record)
+	slog.Debug("Returning record", "record", record)
 	return w.Write(record)
 }